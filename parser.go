@@ -3,8 +3,11 @@ package main
 import (
 	"fmt"
 	"log"
+	"math"
+	"strconv"
 	"strings"
 	"syscall/js"
+	"unicode"
 )
 
 const src = `async function on_click() {
@@ -13,18 +16,65 @@ const src = `async function on_click() {
 
 // Parser ...
 type Parser struct {
-	stack []stack
-	err   error
+	stack    []stack
+	temps    int
+	classes  map[string]*class
+	arity    map[string]int    // parameters of translated functions
+	variadic map[string]bool   // translated functions with a rest parameter
+	returns  map[string]string // result types of translated functions
+	errors   map[string]bool   // translated functions with an error result
+	// widening is set while varType scans assignments
+	widening bool
+	err      error
+}
+
+// class describes a JS class translated to a Go struct.
+type class struct {
+	name     string
+	recv     string
+	base     *class
+	params   []string // rendered constructor parameters
+	fields   []string
+	types    map[string]string // field types
+	methods  map[string]string // method result types
+	getters  map[string]string // getter result types
+	setters  map[string]bool
+	statics  map[string]string // static method result types
+	arity    map[string]int    // parameters of the constructor and methods
+	variadic map[string]bool   // constructor and methods with a rest parameter
 }
 
 type stack struct {
 	obj    js.Value
 	scope  map[string]bool
+	types  map[string]string
+	funcs  map[string]js.Value // functions translated to Go funcs by name
 	define bool
+	block  bool
+	result string
+	this   string
 	prefix []string
 	suffix []string
+	ctl    string     // control code of the jumps out of a try statement
+	ret    string     // result of the returns out of a try statement
+	jumps  []js.Value // jumps out of a try statement by control code
 }
 
+// Go types of translated expressions.
+const (
+	typeValue  = "js.Value"
+	typeString = "string"
+	typeInt    = "int"
+	typeFloat  = "float64"
+	typeBool   = "bool"
+	typeObject = "map[string]interface{}"
+	typeArray  = "[]interface{}"
+	typeAny    = "interface{}"
+)
+
+// typeClass is the type of the identifier of a translated class.
+const typeClass = "class"
+
 func (s *stack) append(src []string) []string {
 	res := s.prefix
 	res = append(res, src...)
@@ -36,7 +86,9 @@ func (s *stack) append(src []string) []string {
 func (p *Parser) ParseProgram(obj js.Value) ([]string, error) {
 	p.push(obj)
 	defer p.pop()
-	res := p.parseArray(obj.Get("body"))
+	// scripts run with this bound to the global object
+	p.stack[0].this = "js.Global()"
+	res := p.parseStatements(obj.Get("body"))
 	if p.err != nil {
 		return nil, p.err
 	}
@@ -44,7 +96,11 @@ func (p *Parser) ParseProgram(obj js.Value) ([]string, error) {
 }
 
 func (p *Parser) push(obj js.Value) {
-	p.stack = append(p.stack, stack{obj: obj, scope: map[string]bool{}})
+	p.stack = append(p.stack, stack{
+		obj:   obj,
+		scope: map[string]bool{},
+		types: map[string]string{},
+	})
 }
 
 func (p *Parser) pop() stack {
@@ -65,10 +121,44 @@ func (p *Parser) getDefineMode() bool {
 	return p.stack[len(p.stack)-1].define
 }
 
+func (p *Parser) setResult(tp string) {
+	p.stack[len(p.stack)-1].result = tp
+}
+
 func (p *Parser) define(sym string, native bool) {
 	p.stack[len(p.stack)-1].scope[sym] = native
 }
 
+func (p *Parser) declare(sym, tp string) {
+	p.define(sym, tp != typeValue)
+	p.stack[len(p.stack)-1].types[sym] = tp
+}
+
+// declareFunc records that sym, defined in the current scope, is the Go
+// func translating the function fn.
+func (p *Parser) declareFunc(sym string, fn js.Value) {
+	top := &p.stack[len(p.stack)-1]
+	if top.funcs == nil {
+		top.funcs = map[string]js.Value{}
+	}
+	top.funcs[sym] = fn
+	if p.arity == nil {
+		p.arity, p.variadic = map[string]int{}, map[string]bool{}
+	}
+	p.arity[sym], p.variadic[sym] = arity(fn), variadic(fn)
+}
+
+// funcOf returns the function translated to the Go func sym, if any.
+func (p *Parser) funcOf(sym string) (js.Value, bool) {
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		if _, ok := p.stack[i].scope[sym]; ok {
+			fn, ok := p.stack[i].funcs[sym]
+			return fn, ok
+		}
+	}
+	return js.Value{}, false
+}
+
 func (p *Parser) defined(sym string) bool {
 	for i := len(p.stack) - 1; i >= 0; i-- {
 		_, ok := p.stack[i].scope[sym]
@@ -89,6 +179,397 @@ func (p *Parser) definedAndType(sym string) (bool, bool) {
 	return false, false
 }
 
+// temp returns a fresh Go identifier for a value introduced by the
+// translation itself.
+func (p *Parser) temp(name string) string {
+	for {
+		p.temps++
+		sym := fmt.Sprintf("%s%d", name, p.temps)
+		if !p.defined(sym) {
+			return sym
+		}
+	}
+}
+
+func (p *Parser) typeOfSymbol(sym string) string {
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		if native, ok := p.stack[i].scope[sym]; ok {
+			if tp, ok := p.stack[i].types[sym]; ok {
+				return tp
+			}
+			if !native {
+				return typeValue
+			}
+			return typeAny
+		}
+	}
+	return typeValue
+}
+
+// typeOf infers the Go type of the translated expression obj.
+func (p *Parser) typeOf(obj js.Value) string {
+	switch obj.Get("type").String() {
+	case "Literal":
+		switch v := obj.Get("value"); v.Type() {
+		case js.TypeBoolean:
+			return typeBool
+		case js.TypeNumber:
+			if isInt(v.Float()) {
+				return typeInt
+			}
+			return typeFloat
+		case js.TypeString:
+			return typeString
+		}
+	case "Identifier":
+		name := obj.Get("name").String()
+		if name == "window" {
+			return typeValue
+		}
+		return p.typeOfSymbol(name)
+	case "TemplateLiteral":
+		return typeString
+	case "TaggedTemplateExpression":
+		return p.typeOf(taggedCall(obj))
+	case "ObjectExpression":
+		props := obj.Get("properties")
+		for i := 0; i < props.Length(); i++ {
+			if v := props.Index(i); v.Get("type").String() == "SpreadElement" && p.typeOf(v.Get("argument")) != typeObject {
+				return typeValue
+			}
+		}
+		return typeObject
+	case "ArrayExpression":
+		return typeArray
+	case "ThisExpression":
+		if b := p.this(); b != nil {
+			return p.typeOfSymbol(b.this)
+		}
+		return typeValue
+	case "Super":
+		if _, c := p.super(); c != nil {
+			return "*" + c.name
+		}
+		return typeValue
+	case "ChainExpression":
+		return typeValue
+	case "MemberExpression":
+		if isOptionalChain(obj) {
+			return typeValue
+		}
+		if c, name, static := p.classMember(obj); c != nil {
+			if _, tp := c.member("field", name); tp != "" && !static {
+				return tp
+			}
+			if _, tp := c.member("get", name); tp != "" && !static {
+				return tp
+			}
+			return typeAny
+		}
+		switch p.typeOf(obj.Get("object")) {
+		case typeArray:
+			switch prop := obj.Get("property"); {
+			case !obj.Get("computed").Bool() && p.parseIdentifier(prop) == "length":
+				return typeInt
+			case obj.Get("computed").Bool() && p.typeOf(prop) == typeInt:
+				return typeAny
+			}
+			return typeValue
+		case typeObject:
+			return typeAny
+		}
+		return typeValue
+	case "NewExpression":
+		if c := p.classRef(obj.Get("callee")); c != nil {
+			return "*" + c.name
+		}
+		return typeValue
+	case "AwaitExpression":
+		if arg := obj.Get("argument"); p.isAsyncCall(arg) {
+			if tp := p.returns[p.parseIdentifier(arg.Get("callee"))]; tp != "" {
+				return tp
+			}
+		}
+		return typeValue
+	case "ConditionalExpression":
+		types := []string{}
+		for _, key := range []string{"consequent", "alternate"} {
+			if v := obj.Get(key); p.isNullish(v) {
+				types = append(types, typeValue)
+			} else {
+				types = append(types, p.typeOf(v))
+			}
+		}
+		return unify(types)
+	case "SequenceExpression":
+		exprs := obj.Get("expressions")
+		return p.typeOf(exprs.Index(exprs.Length() - 1))
+	case "UpdateExpression":
+		if tp := p.typeOf(obj.Get("argument")); tp == typeInt || tp == typeFloat {
+			return tp
+		}
+		return typeFloat
+	case "UnaryExpression":
+		switch obj.Get("operator").String() {
+		case "!", "delete":
+			return typeBool
+		case "-", "+":
+			if p.typeOf(obj.Get("argument")) == typeInt {
+				return typeInt
+			}
+			return typeFloat
+		case "~":
+			return typeInt
+		case "typeof":
+			return typeString
+		}
+		return typeValue
+	case "BinaryExpression":
+		lt, rt := p.typeOf(obj.Get("left")), p.typeOf(obj.Get("right"))
+		switch obj.Get("operator").String() {
+		case "+":
+			if lt == typeString || rt == typeString {
+				return typeString
+			}
+			return numeric(lt, rt)
+		case "-", "*", "%":
+			return numeric(lt, rt)
+		case "/", "**":
+			return typeFloat
+		case "&", "|", "^", "<<", ">>", ">>>":
+			return typeInt
+		default:
+			return typeBool
+		}
+	case "LogicalExpression":
+		lt, rt := p.typeOf(obj.Get("left")), p.typeOf(obj.Get("right"))
+		switch {
+		case obj.Get("operator").String() == "??" && lt != typeValue && lt != typeAny:
+			return lt
+		case lt == rt && lt != typeAny:
+			return lt
+		}
+		return typeValue
+	case "CallExpression":
+		if isOptionalChain(obj) {
+			return typeValue
+		}
+		callee := obj.Get("callee")
+		switch callee.Get("type").String() {
+		case "Identifier":
+			sym := p.parseIdentifier(callee)
+			if def, native := p.definedAndType(sym); def && native {
+				if p.errors[sym] {
+					// a promise, like the call of an async function
+					return typeValue
+				}
+				if tp, ok := p.returns[sym]; ok {
+					return tp
+				}
+				return typeAny
+			}
+		case "MemberExpression":
+			if c, name, static := p.classMember(callee); c != nil {
+				kind := "method"
+				if static {
+					kind = "static"
+				}
+				decl, tp := c.member(kind, name)
+				if decl == nil {
+					return typeValue
+				}
+				// "" for methods without result
+				return tp
+			}
+		}
+		return typeValue
+	}
+	return typeAny
+}
+
+// isInt reports whether the number f is an integer exactly represented
+// by a float64.
+func isInt(f float64) bool {
+	return f == math.Trunc(f) && math.Abs(f) <= 1<<53
+}
+
+// numeric returns the Go type of JS arithmetic on operands of lt and rt.
+func numeric(lt, rt string) string {
+	if lt == typeInt && rt == typeInt {
+		return typeInt
+	}
+	return typeFloat
+}
+
+// parseExpression renders obj as a single Go expression.
+func (p *Parser) parseExpression(obj js.Value) string {
+	return strings.Join(p.parseStatement(obj), "\n")
+}
+
+// parseOperand renders obj like parseExpression and parenthesizes it
+// when it is not a primary expression.
+func (p *Parser) parseOperand(obj js.Value) string {
+	res := p.parseExpression(obj)
+	switch obj.Get("type").String() {
+	case "BinaryExpression", "LogicalExpression", "ConditionalExpression",
+		"AssignmentExpression", "UnaryExpression":
+		return "(" + res + ")"
+	}
+	return res
+}
+
+// parseCondition renders obj as a Go bool expression that follows the
+// JS truthiness rules.
+func (p *Parser) parseCondition(obj js.Value) string {
+	if obj.Get("type").String() == "LogicalExpression" {
+		switch op := obj.Get("operator").String(); op {
+		case "&&", "||":
+			operand := func(v js.Value) string {
+				res := p.parseCondition(v)
+				if op == "&&" && v.Get("type").String() == "LogicalExpression" &&
+					v.Get("operator").String() == "||" {
+					return "(" + res + ")"
+				}
+				return res
+			}
+			right := obj.Get("right")
+			return fmt.Sprintf("%s %s %s", operand(obj.Get("left")), op,
+				p.parseLazy(typeBool, func() string { return operand(right) }))
+		}
+	}
+	switch tp := p.typeOf(obj); tp {
+	case typeBool:
+		return p.parseExpression(obj)
+	case typeAny:
+		return truthy(fmt.Sprintf("js.ValueOf(%s)", p.parseExpression(obj)), typeValue)
+	default:
+		return truthy(p.parseOperand(obj), tp)
+	}
+}
+
+// parseNegatedCondition renders the negation of parseCondition(obj).
+func (p *Parser) parseNegatedCondition(obj js.Value) string {
+	switch tp := p.typeOf(obj); tp {
+	case typeBool:
+		switch obj.Get("type").String() {
+		case "Literal", "Identifier", "CallExpression", "MemberExpression":
+			return "!" + p.parseExpression(obj)
+		}
+		return "!(" + p.parseCondition(obj) + ")"
+	case typeAny:
+		return "!" + p.parseCondition(obj)
+	default:
+		return falsy(p.parseOperand(obj), tp)
+	}
+}
+
+// truthy renders the JS truthiness test of the Go expression expr of type tp.
+func truthy(expr, tp string) string {
+	switch tp {
+	case typeBool:
+		return expr
+	case typeValue:
+		return expr + ".Truthy()"
+	case typeString:
+		return expr + ` != ""`
+	case typeInt, typeFloat:
+		return expr + " != 0"
+	}
+	return fmt.Sprintf("js.ValueOf(%s).Truthy()", expr)
+}
+
+// falsy renders the negation of truthy(expr, tp).
+func falsy(expr, tp string) string {
+	switch tp {
+	case typeString:
+		return expr + ` == ""`
+	case typeInt, typeFloat:
+		return expr + " == 0"
+	}
+	return "!" + truthy(expr, tp)
+}
+
+// parseAs renders obj converted to the Go type tp; function literals and
+// Go funcs converted to a JS value are wrapped for JS to call.
+func (p *Parser) parseAs(obj js.Value, tp string) string {
+	if tp == typeAny || tp == typeValue {
+		obj = p.funcValue(obj)
+	}
+	from := p.typeOf(obj)
+	switch {
+	case isFunction(obj) && tp == typeAny:
+		return strings.Join(p.parseCallback(obj), "\n")
+	case isFunction(obj) && tp == typeValue:
+		return strings.Join(p.parseCallback(obj), "\n") + ".Value"
+	case from == tp, tp == typeAny:
+		return p.parseExpression(obj)
+	case tp == typeBool:
+		return p.parseCondition(obj)
+	case tp == typeValue:
+		if obj.Get("type").String() == "Literal" && obj.Get("value").IsNull() {
+			return "js.Null()"
+		}
+		return fmt.Sprintf("js.ValueOf(%s)", p.parseExpression(obj))
+	case tp == typeString:
+		if from == typeInt || from == typeBool {
+			return fmt.Sprintf("fmt.Sprint(%s)", p.parseExpression(obj))
+		}
+		if obj.Get("type").String() == "Literal" {
+			return fmt.Sprintf("%q", js.Global().Call("String", obj.Get("value")).String())
+		}
+		// String of a js.Value not holding a string describes its type,
+		// and Go formats floats unlike JS, 1e+06 for 1000000
+		return fmt.Sprintf("js.Global().Call(\"String\", %s).String()", p.parseExpression(obj))
+	}
+	// numeric conversions
+	switch from {
+	case typeValue:
+		if tp == typeInt {
+			return p.parseOperand(obj) + ".Int()"
+		}
+		return p.parseOperand(obj) + ".Float()"
+	case typeInt, typeFloat:
+		if isNumericLiteral(obj) {
+			return p.parseExpression(obj)
+		}
+		return fmt.Sprintf("%s(%s)", tp, p.parseExpression(obj))
+	}
+	res := fmt.Sprintf("js.Global().Call(\"Number\", %s)", p.parseExpression(obj))
+	if tp == typeInt {
+		return res + ".Int()"
+	}
+	return res + ".Float()"
+}
+
+// isNumericLiteral reports whether obj is a number literal, possibly
+// negated, which Go renders as an untyped constant.
+func isNumericLiteral(obj js.Value) bool {
+	if obj.Get("type").String() == "UnaryExpression" && obj.Get("operator").String() == "-" {
+		obj = obj.Get("argument")
+	}
+	return obj.Get("type").String() == "Literal" && obj.Get("value").Type() == js.TypeNumber
+}
+
+// parseOperandAs renders obj like parseAs and parenthesizes it when it is
+// not converted and not a primary expression.
+func (p *Parser) parseOperandAs(obj js.Value, tp string) string {
+	if from := p.typeOf(obj); from == tp || tp == typeAny {
+		return p.parseOperand(obj)
+	}
+	return p.parseAs(obj, tp)
+}
+
+// isNullish reports whether obj is the null literal or undefined.
+func (p *Parser) isNullish(obj js.Value) bool {
+	switch obj.Get("type").String() {
+	case "Literal":
+		return obj.Get("value").IsNull()
+	case "Identifier":
+		return p.parseIdentifier(obj) == "undefined" && !p.defined("undefined")
+	}
+	return false
+}
+
 func (p *Parser) parseIdentifier(obj js.Value) string {
 	return obj.Get("name").String()
 }
@@ -107,14 +588,27 @@ func (p *Parser) parseIdentifierRef(obj js.Value) []string {
 
 func (p *Parser) parseProperty(obj js.Value) []string {
 	console.Call("log", p.indent(), "Property:", obj)
-	key := p.parseStatement(obj.Get("key"))[0]
-	value := p.parseStatement(obj.Get("value"))[0]
-	res := []string{fmt.Sprintf("%q: %s", key, value)}
+	res := []string{fmt.Sprintf("%s: %s", p.parsePropertyKey(obj), p.parseAs(obj.Get("value"), typeAny))}
 	return res
 }
 
+// parsePropertyKey renders the key of the property obj as a Go string.
+func (p *Parser) parsePropertyKey(obj js.Value) string {
+	key := obj.Get("key")
+	switch {
+	case obj.Get("computed").Bool():
+		return p.parseAs(key, typeString)
+	case key.Get("type").String() == "Literal":
+		return fmt.Sprintf("%q", js.Global().Call("String", key.Get("value")).String())
+	}
+	return fmt.Sprintf("%q", p.parseIdentifier(key))
+}
+
 func (p *Parser) parseArrayExpression(obj js.Value) []string {
 	console.Call("log", p.indent(), "ArrayExpression:", obj)
+	if hasSpread(obj.Get("elements")) {
+		return []string{p.parseSpreadList(obj.Get("elements"))}
+	}
 	elements := p.parseArray(obj.Get("elements"))
 	res := []string{"[]interface{}{"}
 	switch len(elements) {
@@ -133,8 +627,111 @@ func (p *Parser) parseArrayExpression(obj js.Value) []string {
 
 func (p *Parser) parseThisExpression(obj js.Value) []string {
 	console.Call("log", p.indent(), "ThisExpression:", obj)
-	res := []string{}
-	return res
+	if b := p.this(); b != nil {
+		return []string{b.this}
+	}
+	p.err = fmt.Errorf("unsupported this in function not called by JS")
+	return []string{}
+}
+
+// parseTemplateLiteral renders the template obj as a concatenation when
+// all its values are strings, or as fmt.Sprintf otherwise.
+func (p *Parser) parseTemplateLiteral(obj js.Value) []string {
+	console.Call("log", p.indent(), "TemplateLiteral:", obj)
+	quasis, exprs := obj.Get("quasis"), obj.Get("expressions")
+	concat := true
+	for i := 0; i < exprs.Length(); i++ {
+		concat = concat && p.typeOf(exprs.Index(i)) == typeString
+	}
+	parts, format, values := []string{}, "", []string{}
+	for i := 0; i < quasis.Length(); i++ {
+		text := quasis.Index(i).Get("value").Get("cooked").String()
+		if text != "" || exprs.Length() == 0 {
+			parts = append(parts, fmt.Sprintf("%q", text))
+		}
+		format += strings.Replace(text, "%", "%%", -1)
+		if i >= exprs.Length() {
+			break
+		}
+		v := exprs.Index(i)
+		parts = append(parts, p.parseOperand(v))
+		switch p.typeOf(v) {
+		case typeString:
+			format += "%s"
+			values = append(values, p.parseExpression(v))
+		case typeInt:
+			format += "%d"
+			values = append(values, p.parseExpression(v))
+		case typeBool:
+			format += "%t"
+			values = append(values, p.parseExpression(v))
+		default:
+			format += "%s"
+			values = append(values, p.parseAs(v, typeString))
+		}
+	}
+	if concat {
+		return []string{strings.Join(parts, " + ")}
+	}
+	return []string{fmt.Sprintf("fmt.Sprintf(%q, %s)", format, strings.Join(values, ", "))}
+}
+
+// parseTaggedTemplateExpression renders the tagged template obj as the
+// call of its tag with the strings of the template and its values.
+func (p *Parser) parseTaggedTemplateExpression(obj js.Value) []string {
+	console.Call("log", p.indent(), "TaggedTemplateExpression:", obj)
+	return p.parseCallExpression(taggedCall(obj))
+}
+
+// taggedCall returns the call expression the tagged template obj stands
+// for: the tag gets the cooked strings as an array with the raw strings
+// as its raw property, Object.assign([cooked...], {raw: [raw...]}).
+func taggedCall(obj js.Value) js.Value {
+	quasi := obj.Get("quasi")
+	strs := map[string][]interface{}{}
+	for i := 0; i < quasi.Get("quasis").Length(); i++ {
+		for _, kind := range []string{"cooked", "raw"} {
+			// cooked is undefined for invalid escapes
+			strs[kind] = append(strs[kind], map[string]interface{}{
+				"type":  "Literal",
+				"value": quasi.Get("quasis").Index(i).Get("value").Get(kind),
+			})
+		}
+	}
+	ident := func(name string) map[string]interface{} {
+		return map[string]interface{}{"type": "Identifier", "name": name}
+	}
+	args := []interface{}{map[string]interface{}{
+		"type": "CallExpression",
+		"callee": map[string]interface{}{
+			"type":     "MemberExpression",
+			"object":   ident("Object"),
+			"property": ident("assign"),
+			"computed": false,
+		},
+		"arguments": []interface{}{
+			map[string]interface{}{"type": "ArrayExpression", "elements": strs["cooked"]},
+			map[string]interface{}{"type": "ObjectExpression", "properties": []interface{}{
+				map[string]interface{}{
+					"type":      "Property",
+					"key":       ident("raw"),
+					"value":     map[string]interface{}{"type": "ArrayExpression", "elements": strs["raw"]},
+					"kind":      "init",
+					"computed":  false,
+					"method":    false,
+					"shorthand": false,
+				},
+			}},
+		},
+	}}
+	for i := 0; i < quasi.Get("expressions").Length(); i++ {
+		args = append(args, quasi.Get("expressions").Index(i))
+	}
+	return js.ValueOf(map[string]interface{}{
+		"type":      "CallExpression",
+		"callee":    obj.Get("tag"),
+		"arguments": args,
+	})
 }
 
 func (p *Parser) parseLiteral(obj js.Value) []string {
@@ -147,7 +744,12 @@ func (p *Parser) parseLiteral(obj js.Value) []string {
 	case js.TypeBoolean:
 		res = fmt.Sprint(v.Bool())
 	case js.TypeNumber:
-		res = fmt.Sprint(v.Float())
+		if f := v.Float(); isInt(f) {
+			// an integer constant, as typeOf types it
+			res = strconv.FormatFloat(f, 'f', -1, 64)
+		} else {
+			res = fmt.Sprint(f)
+		}
 	case js.TypeString:
 		res = fmt.Sprintf("%q", v.String())
 	case js.TypeObject:
@@ -162,20 +764,54 @@ func (p *Parser) parseVariableDeclaration(obj js.Value) []string {
 	console.Call("log", p.indent(), "VariableDeclaration:", obj)
 	kind := obj.Get("kind").String()
 	decls := obj.Get("declarations")
+	patterns := false
+	for i := 0; i < decls.Length(); i++ {
+		patterns = patterns || decls.Index(i).Get("id").Get("type").String() != "Identifier"
+	}
+	if kind == "const" && !constants(decls) {
+		// Go constants hold constant values only
+		kind = "let"
+	}
 	if kind == "let" {
 		if len(p.stack) > 1 {
 			// := statement
 			res := []string{}
-			for _, v := range p.parseArray(decls) {
-				res = append(res, strings.Replace(v, "=", ":=", 1))
+			for i := 0; i < decls.Length(); i++ {
+				decl := p.parseStatement(decls.Index(i))
+				if decls.Index(i).Get("id").Get("type").String() != "Identifier" {
+					// destructuring renders complete statements
+					res = append(res, decl...)
+					continue
+				}
+				if strings.Contains(decl[0], "=") {
+					decl[0] = strings.Replace(decl[0], "=", ":=", 1)
+				} else {
+					decl[0] = "var " + decl[0]
+				}
+				res = append(res, decl...)
 			}
 			return res
 		}
 		kind = "var"
 	}
-	if decls.Length() == 1 {
-		res := p.parseArray(decls)
-		return append([]string{fmt.Sprintf("%s %s", kind, res[0])}, res[1:]...)
+	if decls.Length() == 1 || p.function() != nil || patterns {
+		// one declaration each, as declarators may expand to statements
+		res := []string{}
+		for i := 0; i < decls.Length(); i++ {
+			decl := p.parseStatement(decls.Index(i))
+			if decls.Index(i).Get("id").Get("type").String() != "Identifier" {
+				res = append(res, decl...)
+				continue
+			}
+			if strings.Contains(decl[0], "=") {
+				res = append(res, fmt.Sprintf("%s %s", kind, decl[0]))
+			} else {
+				// declared without a value
+				res = append(res, "var "+decl[0])
+			}
+			res = append(res, decl[1:]...)
+		}
+		return res
 	}
 	res := []string{kind + " ("}
 	res = append(res, p.parseArray(decls)...)
@@ -183,361 +819,3413 @@ func (p *Parser) parseVariableDeclaration(obj js.Value) []string {
 	return res
 }
 
+// constants reports whether the declarators decls bind identifiers to
+// string, number or boolean literals.
+func constants(decls js.Value) bool {
+	for i := 0; i < decls.Length(); i++ {
+		decl := decls.Index(i)
+		if decl.Get("id").Get("type").String() != "Identifier" || decl.Get("init").IsNull() ||
+			decl.Get("init").Get("type").String() != "Literal" {
+			return false
+		}
+		switch decl.Get("init").Get("value").Type() {
+		case js.TypeString, js.TypeNumber, js.TypeBoolean:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 func (p *Parser) parseVariableDeclarator(obj js.Value) []string {
 	console.Call("log", p.indent(), "VariableDeclarator:", obj)
-	id := p.parseIdentifier(obj.Get("id"))
-	p.define(id, true)
 	init := obj.Get("init")
+	if pattern := obj.Get("id"); pattern.Get("type").String() != "Identifier" {
+		return p.parseDestructuring(pattern, init)
+	}
+	id := p.parseIdentifier(obj.Get("id"))
 	if init.IsNull() {
-		return []string{fmt.Sprintf("%s = nil", id)}
+		p.declare(id, typeValue)
+		return []string{fmt.Sprintf("%s js.Value", id)}
 	}
+	tp := p.varType(id, init, p.scope())
 	switch init.Get("type").String() {
 	case "AwaitExpression":
-		id += ", err"
-	case "MemberExpression":
-		prop := p.parseMemberExpression(init)
-		return []string{fmt.Sprintf("%s = %s.Get(%q)", id, prop[0], prop[1])}
-	default:
+		p.declare(id, tp)
+		return append([]string{fmt.Sprintf("%s, err = %s", id, p.await(init))}, p.checkError("err")...)
+	case "ConditionalExpression":
+		if p.function() != nil {
+			// declare the variable and assign it in if/else branches
+			p.declare(id, tp)
+			return append([]string{fmt.Sprintf("%s %s", id, tp)}, p.parseConditionalAssignment(id, init, tp)...)
+		}
+	}
+	if p.typeOf(init) != tp {
+		value := p.parseInit(init, tp)
+		p.declare(id, tp)
+		return []string{fmt.Sprintf("%s = %s", id, value)}
+	}
+	p.declare(id, tp)
+	if isFunction(init) {
+		p.declareFunc(id, init)
 	}
 	res := p.parseStatement(init)
 	return append([]string{fmt.Sprintf("%s = %s", id, res[0])}, res[1:]...)
 }
 
-func (p *Parser) buildGetChain(args []string) string {
-	res := []string{}
-	if p.stack[len(p.stack)-1].obj.Get("type").String() == "CallExpression" {
-		res = append(res, "js.Global()")
-	} else {
-		res = append(res, args[0])
-		args = args[1:]
+// parseInit renders the initial value init of a variable of type tp.
+func (p *Parser) parseInit(init js.Value, tp string) string {
+	if isNumericLiteral(init) && tp != p.typeOf(init) {
+		// the type of an untyped constant is the default one
+		return fmt.Sprintf("%s(%s)", tp, p.parseExpression(init))
+	}
+	return p.parseAs(init, tp)
+}
+
+// varType returns the Go type of the variable id initialized to init in
+// the scope body: float64 rather than int when it is assigned numbers
+// other than integers or JS values, and js.Value for array and object
+// literals shared with functions.
+func (p *Parser) varType(id string, init, body js.Value) string {
+	tp := p.typeOf(init)
+	if (tp == typeArray || tp == typeObject) && shared(body, func(v js.Value) bool {
+		return v.Get("type").String() == "Identifier" && p.parseIdentifier(v) == id
+	}) {
+		return typeValue
+	}
+	if tp != typeInt || p.widening {
+		return tp
+	}
+	p.widening = true
+	defer func() { p.widening = false }()
+	assigned := func(v js.Value) bool {
+		left := v.Get("left")
+		if v.Get("type").String() != "AssignmentExpression" || left.Get("type").String() != "Identifier" ||
+			p.parseIdentifier(left) != id {
+			return true
+		}
+		switch v.Get("operator").String() {
+		case "=", "+=", "-=", "*=", "%=":
+			if p.typeOf(v.Get("right")) != typeInt {
+				tp = typeFloat
+			}
+		case "/=", "**=":
+			tp = typeFloat
+		}
+		return true
+	}
+	p.scanBody(body, func(v js.Value) {
+		if isFunction(v) {
+			// closures assign the variable too
+			walk(v.Get("body"), assigned)
+			return
+		}
+		assigned(v)
+	})
+	return tp
+}
+
+// shared reports whether a value the test is matches in body receives a
+// method call or is passed to a function, which has to see the changes
+// made to it: a Go slice or map is converted to a fresh JS value on each
+// call.
+func shared(body js.Value, is func(js.Value) bool) bool {
+	found := false
+	walk(body, func(v js.Value) bool {
+		switch v.Get("type").String() {
+		case "CallExpression", "NewExpression":
+			callee, args := v.Get("callee"), v.Get("arguments")
+			found = callee.Get("type").String() == "MemberExpression" && is(callee.Get("object"))
+			for i := 0; i < args.Length(); i++ {
+				found = found || is(args.Index(i))
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// scope returns the body of the innermost function being translated, or
+// the body of the program.
+func (p *Parser) scope() js.Value {
+	if fn := p.function(); fn != nil {
+		return fn.obj.Get("body")
+	}
+	return p.stack[0].obj.Get("body")
+}
+
+// parseDestructuring renders the declaration of the bindings of pattern
+// destructuring the value of init.
+func (p *Parser) parseDestructuring(pattern, init js.Value) []string {
+	if res, ok := p.parseParallelBinding(pattern, init, false); ok {
+		return []string{res}
 	}
-	for _, v := range args {
-		res = append(res, fmt.Sprintf("Get(%q)", v))
+	if init.Get("type").String() != "AwaitExpression" {
+		return p.parseBinding(pattern, p.parseExpression(init), p.typeOf(init), false)
 	}
-	return strings.Join(res, ".")
+	// await into a temporary declared like any other variable
+	src := p.temp("src")
+	res := p.parseVariableDeclaration(js.ValueOf(map[string]interface{}{
+		"type": "VariableDeclaration",
+		"kind": "let",
+		"declarations": []interface{}{map[string]interface{}{
+			"type": "VariableDeclarator",
+			"id":   map[string]interface{}{"type": "Identifier", "name": src},
+			"init": init,
+		}},
+	}))
+	return append(res, p.parseBinding(pattern, src, typeValue, false)...)
 }
 
 func (p *Parser) parseMemberExpression(obj js.Value) []string {
 	console.Call("log", p.indent(), "MemberExpression:", obj)
-	target := obj.Get("object")
-	res := []string{}
-	switch target.Get("type").String() {
-	case "MemberExpression":
-		res = append(res, p.buildGetChain(p.parseStatement(target)))
-	default:
-		res = append(res, p.parseStatement(target)...)
+	if isOptionalChain(obj) {
+		return []string{p.parseOptionalChain(obj, "")}
 	}
-	res = append(res, p.parseIdentifier(obj.Get("property")))
-	return res
+	if c, name, static := p.classMember(obj); c != nil {
+		return []string{p.parseClassMember(obj, c, name, static)}
+	}
+	object, key, index := p.parseMemberKey(obj)
+	switch tp := p.typeOf(obj.Get("object")); {
+	case tp == typeArray && key == `"length"`:
+		return []string{fmt.Sprintf("len(%s)", object)}
+	case tp == typeObject:
+		return []string{fmt.Sprintf("%s[%s]", object, key)}
+	case tp == typeArray && index:
+		return []string{p.parseSliceIndex(object, key)}
+	case index:
+		return []string{fmt.Sprintf("%s.Index(%s)", object, key)}
+	}
+	return []string{fmt.Sprintf("%s.Get(%s)", object, key)}
 }
 
 func (p *Parser) parseCallExpression(obj js.Value) []string {
 	console.Call("log", p.indent(), "CallExpression:", obj)
+	if isOptionalChain(obj) {
+		return []string{p.parseOptionalChain(obj, "")}
+	}
 	callee := obj.Get("callee")
 	args := obj.Get("arguments")
 	switch callee.Get("type").String() {
+	case "Super":
+		// super(...) initializes the embedded base
+		base, c := p.super()
+		if c == nil {
+			p.err = fmt.Errorf("unsupported super outside of derived class constructor")
+			return []string{}
+		}
+		return []string{fmt.Sprintf("%s = New%s(%s)", base, c.name,
+			p.parseGoArguments(args, c.arity["constructor"], c.variadic["constructor"]))}
 	default:
-		res := p.parseStatement(callee)
-		switch args.Length() {
-		case 0:
-			res[len(res)-1] += "()"
-		case 1:
-			param := p.parseStatement(args.Index(0))
-			res[len(res)-1] += fmt.Sprintf("(%s, ", param[0])
-			res = append(res, param[1:]...)
-			res[len(res)-1] += ")"
-		default:
-			res[len(res)-1] += "("
-			for i := 0; i < args.Length(); i++ {
-				res = append(res, p.parseStatement(args.Index(i))...)
-				res[len(res)-1] += ","
-			}
-			res = append(res, ")")
+		if isFunction(callee) {
+			return []string{fmt.Sprintf("%s(%s)", p.parseOperand(callee), p.parseArguments(args, typeValue))}
 		}
-		return res
+		return []string{fmt.Sprintf("%s.Invoke(%s)",
+			p.parseOperandAs(callee, typeValue), p.parseArguments(args, typeAny))}
 	case "Identifier":
 		sym := p.parseIdentifier(callee)
-		res := []string{}
 		if def, tp := p.definedAndType(sym); def {
-			if tp {
-				res = append(res, fmt.Sprintf("%s(", sym))
-			} else {
-				res = append(res, fmt.Sprintf("%s.Invoke(", sym))
-			}
-			switch args.Length() {
-			case 0:
-				res[len(res)-1] += ")"
-			case 1:
-				res[len(res)-1] += p.parseStatement(args.Index(0))[0]
-				res[len(res)-1] += ")"
-			default:
-				for i := 0; i < args.Length(); i++ {
-					res = append(res, p.parseStatement(args.Index(i))...)
-					res[len(res)-1] += ","
-				}
-				res = append(res, ")")
+			if p.isAsyncCall(obj) {
+				return []string{p.parsePromise(obj)}
 			}
-		} else {
-			res = append(res, fmt.Sprintf("js.Global().Call(%q", sym))
-			log.Println("sym", sym, "args", args, res)
-			switch args.Length() {
-			case 0:
-				res[len(res)-1] += ")"
-			case 1:
-				res[len(res)-1] += ", "
-				res[len(res)-1] += p.parseStatement(args.Index(0))[0]
-				res[len(res)-1] += ")"
-			default:
-				for i := 0; i < args.Length(); i++ {
-					res = append(res, p.parseStatement(args.Index(i))...)
-				}
-				res = append(res, ")")
+			if tp {
+				return []string{p.parseFunctionCall(obj)}
 			}
+			return []string{fmt.Sprintf("%s.Invoke(%s)", sym, p.parseArguments(args, typeAny))}
 		}
-		return res
+		res := fmt.Sprintf("js.Global().Call(%q", sym)
+		log.Println("sym", sym, "args", args, res)
+		if args.Length() > 0 {
+			res += ", " + p.parseArguments(args, typeAny)
+		}
+		return []string{res + ")"}
 	case "MemberExpression":
-		static := p.parseStatement(callee)
-		res, fn := static[:len(static)-1], static[len(static)-1]
-		res[len(res)-1] += fmt.Sprintf(".Call(%q", fn)
-		switch args.Length() {
-		case 0:
-			res[len(res)-1] += ")"
-		case 1:
-			res[len(res)-1] += ", "
-			res[len(res)-1] += p.parseStatement(args.Index(0))[0]
-			res[len(res)-1] += ")"
-		default:
-			for i := 0; i < args.Length(); i++ {
-				res = append(res, p.parseStatement(args.Index(i))...)
+		if c, name, static := p.classMember(callee); c != nil {
+			kind := "method"
+			if static {
+				kind = "static"
+			}
+			if decl, _ := c.member(kind, name); decl != nil {
+				return []string{fmt.Sprintf("%s(%s)", p.parseClassMember(callee, c, name, static),
+					p.parseGoArguments(args, decl.arity[name], decl.variadic[name]))}
+			}
+			if static {
+				return []string{p.parseClassMember(callee, c, name, static)}
 			}
-			res = append(res, ")")
+			return []string{fmt.Sprintf("%s.Invoke(%s)",
+				p.parseOperandAs(callee, typeValue), p.parseArguments(args, typeAny))}
+		}
+		object := p.parseOperandAs(callee.Get("object"), typeValue)
+		if _, key, index := p.parseMemberKey(callee); index {
+			return []string{fmt.Sprintf("%s.Index(%s).Invoke(%s)", object, key, p.parseArguments(args, typeAny))}
+		} else if args.Length() > 0 {
+			return []string{fmt.Sprintf("%s.Call(%s, %s)", object, key, p.parseArguments(args, typeAny))}
+		} else {
+			return []string{fmt.Sprintf("%s.Call(%s)", object, key)}
 		}
-		return res
 	}
 }
 
-func (p *Parser) parseObjectExpression(obj js.Value) []string {
-	console.Call("log", p.indent(), "ObjectExpression:", obj)
-	p.setDefineMode(true)
-	defer p.setDefineMode(false)
-	properties := p.parseArray(obj.Get("properties"))
-	res := []string{}
-	switch len(properties) {
-	case 0:
-		res = append(res, "map[string]interface{}{}")
-	case 1:
-		res = append(res, fmt.Sprintf("map[string]interface{}{%s}", properties[0]))
-	default:
-		res = append(res, "map[string]interface{}{")
-		for _, v := range properties {
-			res = append(res, v+",")
-		}
-		res = append(res, "}")
-	}
-	return res
+// parseFunctionCall renders the call obj of a translated function.
+func (p *Parser) parseFunctionCall(obj js.Value) string {
+	sym, args := p.parseIdentifier(obj.Get("callee")), obj.Get("arguments")
+	return fmt.Sprintf("%s(%s)", sym, p.parseGoArguments(args, p.arity[sym], p.variadic[sym]))
+}
+
+// isAsyncCall reports whether obj calls a translated function with an
+// error result.
+func (p *Parser) isAsyncCall(obj js.Value) bool {
+	if obj.Get("type").String() != "CallExpression" || obj.Get("callee").Get("type").String() != "Identifier" {
+		return false
+	}
+	sym := p.parseIdentifier(obj.Get("callee"))
+	def, native := p.definedAndType(sym)
+	return def && native && p.errors[sym]
+}
+
+// parsePromise renders the call obj of a translated function with an
+// error result as a promise settled by the call in a goroutine, as the
+// call of an async function returns a promise.
+func (p *Parser) parsePromise(obj js.Value) string {
+	res := []string{
+		"js.Global().Get(\"Promise\").New(js.FuncOf(func(_ js.Value, args []js.Value) interface{} {",
+		"go func() {",
+	}
+	resolve := "args[0].Invoke()"
+	if p.returns[p.parseIdentifier(obj.Get("callee"))] != "" {
+		res = append(res, "v, err := "+p.parseFunctionCall(obj))
+		resolve = "args[0].Invoke(v)"
+	} else {
+		res = append(res, "err := "+p.parseFunctionCall(obj))
+	}
+	res = append(res,
+		"if err != nil {",
+		"e := js.Global().Get(\"Error\").New(err.Error())",
+		"if v, ok := err.(js.Error); ok {",
+		"e = v.Value",
+		"}",
+		"args[1].Invoke(e)",
+		"return",
+		"}",
+		resolve,
+		"}()",
+		"return nil",
+		"}))",
+	)
+	return strings.Join(res, "\n")
+}
+
+// hasSpread reports whether the element list has spread elements.
+func hasSpread(list js.Value) bool {
+	for i := 0; i < list.Length(); i++ {
+		if v := list.Index(i); !v.IsNull() && v.Get("type").String() == "SpreadElement" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSpreadList renders the elements list with spread elements as a
+// []interface{} appending the spread values to the other elements.
+func (p *Parser) parseSpreadList(list js.Value) string {
+	res, group := "", []string{}
+	for i := 0; i < list.Length(); i++ {
+		v := list.Index(i)
+		if v.Get("type").String() != "SpreadElement" {
+			group = append(group, p.parseExpression(v))
+			continue
+		}
+		arg := v.Get("argument")
+		switch {
+		case res == "" && len(group) == 0 && p.typeOf(arg) != typeArray:
+			// a converted value is a fresh slice already
+			res = p.parseSpread(arg)
+			continue
+		case res == "":
+			res = fmt.Sprintf("[]interface{}{%s}", strings.Join(group, ", "))
+		case len(group) > 0:
+			res = fmt.Sprintf("append(%s, %s)", res, strings.Join(group, ", "))
+		}
+		group = nil
+		res = fmt.Sprintf("append(%s, %s...)", res, p.parseSpread(arg))
+	}
+	if len(group) > 0 {
+		res = fmt.Sprintf("append(%s, %s)", res, strings.Join(group, ", "))
+	}
+	return res
+}
+
+// parseSpread renders the spread iterable obj as a []interface{}.
+func (p *Parser) parseSpread(obj js.Value) string {
+	if p.typeOf(obj) == typeArray {
+		return p.parseExpression(obj)
+	}
+	arr, res := p.temp("arr"), p.temp("res")
+	return strings.Join([]string{
+		"func() []interface{} {",
+		fmt.Sprintf("%s := js.Global().Get(\"Array\").Call(\"from\", %s)", arr, p.parseAs(obj, typeValue)),
+		fmt.Sprintf("%s := make([]interface{}, %s.Length())", res, arr),
+		fmt.Sprintf("for i := range %s {", res),
+		fmt.Sprintf("%s[i] = %s.Index(i)", res, arr),
+		"}",
+		"return " + res,
+		"}()",
+	}, "\n")
+}
+
+// isOptionalChain reports whether the member or call chain obj has an
+// optional link.
+func isOptionalChain(obj js.Value) bool {
+	for {
+		switch obj.Get("type").String() {
+		case "ChainExpression":
+			return true
+		case "MemberExpression":
+			if obj.Get("optional").Truthy() {
+				return true
+			}
+			obj = obj.Get("object")
+		case "CallExpression":
+			if obj.Get("optional").Truthy() {
+				return true
+			}
+			obj = obj.Get("callee")
+		default:
+			return false
+		}
+	}
+}
+
+// parseOptionalChain renders the member and call chain obj as a closure
+// that stops at the first optional link reading null or undefined and
+// returns undefined, or fallback for a ?? b when it is set. Values tested
+// are kept in temporaries so that each link is evaluated once. The bundled
+// esprima 4 predates ES2020 and does not parse ?. nor ??; the ASTs come
+// from newer ESTree parsers.
+func (p *Parser) parseOptionalChain(obj js.Value, fallback string) string {
+	if obj.Get("type").String() == "ChainExpression" {
+		obj = obj.Get("expression")
+	}
+	chain := []js.Value{}
+	for v := obj; v.Get("type").String() == "MemberExpression" || v.Get("type").String() == "CallExpression"; {
+		chain = append(chain, v)
+		if v.Get("type").String() == "MemberExpression" {
+			v = v.Get("object")
+		} else {
+			v = v.Get("callee")
+		}
+	}
+	// the links above the innermost optional one, with the member read
+	// by an optional call to keep its receiver
+	k := len(chain) - 1
+	for !chain[k].Get("optional").Truthy() {
+		k--
+	}
+	if chain[k].Get("type").String() == "CallExpression" && k+1 < len(chain) &&
+		chain[k+1].Get("type").String() == "MemberExpression" {
+		k++
+	}
+	base := chain[k].Get("object")
+	if chain[k].Get("type").String() == "CallExpression" {
+		base = chain[k].Get("callee")
+	}
+	ret := fallback
+	if ret == "" {
+		ret = "js.Undefined()"
+	}
+	res := []string{"func() js.Value {"}
+	cur := p.parseAs(base, typeValue)
+	// the operands of the links only run when the guards before pass
+	arguments := func(call js.Value) string {
+		args := ""
+		lines := p.hoisted(func() { args = p.parseArguments(call.Get("arguments"), typeAny) })
+		if len(lines) == 0 {
+			return args
+		}
+		list := fmt.Sprintf("[]interface{}{%s}", args)
+		if hasSpread(call.Get("arguments")) {
+			list = strings.TrimSuffix(args, "...")
+		}
+		res := append([]string{"func() []interface{} {"}, lines...)
+		return strings.Join(append(res, "return "+list, "}()..."), "\n")
+	}
+	guard := func() {
+		if !isIdentifier(cur) {
+			v := p.temp("v")
+			res = append(res, fmt.Sprintf("%s := %s", v, cur))
+			cur = v
+		}
+		res = append(res, fmt.Sprintf("if %s.IsUndefined() || %s.IsNull() {", cur, cur), "return "+ret, "}")
+	}
+	for i := k; i >= 0; i-- {
+		link := chain[i]
+		if link.Get("optional").Truthy() {
+			guard()
+		}
+		if link.Get("type").String() == "CallExpression" {
+			cur = fmt.Sprintf("%s.Invoke(%s)", cur, arguments(link))
+			continue
+		}
+		key, index := "", false
+		switch prop := link.Get("property"); {
+		case !link.Get("computed").Bool():
+			key = fmt.Sprintf("%q", p.parseIdentifier(prop))
+		case p.typeOf(prop) == typeInt:
+			key, index = p.parseLazy(typeInt, func() string { return p.parseExpression(prop) }), true
+		default:
+			key = p.parseLazy(typeString, func() string { return p.parseAs(prop, typeString) })
+		}
+		if index {
+			cur = fmt.Sprintf("%s.Index(%s)", cur, key)
+			continue
+		}
+		if i == 0 || chain[i-1].Get("type").String() != "CallExpression" {
+			cur = fmt.Sprintf("%s.Get(%s)", cur, key)
+			continue
+		}
+		// a method call keeps the object as receiver
+		call := chain[i-1]
+		args := arguments(call)
+		if !call.Get("optional").Truthy() {
+			cur = fmt.Sprintf("%s.Call(%s", cur, key)
+			if args != "" {
+				cur += ", " + args
+			}
+			cur += ")"
+			i--
+			continue
+		}
+		if !isIdentifier(cur) {
+			v := p.temp("v")
+			res = append(res, fmt.Sprintf("%s := %s", v, cur))
+			cur = v
+		}
+		recv := cur
+		cur = fmt.Sprintf("%s.Get(%s)", recv, key)
+		guard()
+		if args != "" {
+			args = ", " + args
+		}
+		cur = fmt.Sprintf("%s.Call(\"call\", %s%s)", cur, recv, args)
+		i--
+	}
+	if fallback != "" {
+		res = append(res,
+			fmt.Sprintf("if v := %s; !v.IsUndefined() && !v.IsNull() {", cur),
+			"return v",
+			"}",
+		)
+		cur = fallback
+	}
+	res = append(res, "return "+cur, "}()")
+	return strings.Join(res, "\n")
+}
+
+// parseArguments renders the arguments of a call converted to tp.
+func (p *Parser) parseArguments(args js.Value, tp string) string {
+	if hasSpread(args) {
+		if tp != typeAny {
+			p.err = fmt.Errorf("unsupported spread in call of Go function")
+		}
+		return p.parseSpreadList(args) + "..."
+	}
+	res := []string{}
+	for i := 0; i < args.Length(); i++ {
+		res = append(res, p.parseAs(args.Index(i), tp))
+	}
+	return strings.Join(res, ", ")
+}
+
+func (p *Parser) parseObjectExpression(obj js.Value) []string {
+	console.Call("log", p.indent(), "ObjectExpression:", obj)
+	if hasSpread(obj.Get("properties")) {
+		return []string{p.parseObjectSpread(obj)}
+	}
+	properties := p.parseArray(obj.Get("properties"))
+	res := []string{}
+	switch len(properties) {
+	case 0:
+		res = append(res, "map[string]interface{}{}")
+	case 1:
+		res = append(res, fmt.Sprintf("map[string]interface{}{%s}", properties[0]))
+	default:
+		res = append(res, "map[string]interface{}{")
+		for _, v := range properties {
+			res = append(res, v+",")
+		}
+		res = append(res, "}")
+	}
+	return res
+}
+
+// parseObjectSpread renders the object literal obj with spread elements
+// by merging Go maps, or with Object.assign when it spreads JS values.
+func (p *Parser) parseObjectSpread(obj js.Value) string {
+	props := obj.Get("properties")
+	property := func(v js.Value) (key, value string) {
+		return p.parsePropertyKey(v), p.parseAs(v.Get("value"), typeAny)
+	}
+	if p.typeOf(obj) == typeObject {
+		m := p.temp("m")
+		res := []string{"func() map[string]interface{} {", m + " := map[string]interface{}{}"}
+		for i := 0; i < props.Length(); i++ {
+			v := props.Index(i)
+			if v.Get("type").String() == "SpreadElement" {
+				res = append(res,
+					fmt.Sprintf("for k, v := range %s {", p.parseExpression(v.Get("argument"))),
+					fmt.Sprintf("%s[k] = v", m),
+					"}",
+				)
+				continue
+			}
+			key, value := property(v)
+			res = append(res, fmt.Sprintf("%s[%s] = %s", m, key, value))
+		}
+		return strings.Join(append(res, "return "+m, "}()"), "\n")
+	}
+	args, group := []string{"map[string]interface{}{}"}, []string{}
+	for i := 0; i < props.Length(); i++ {
+		v := props.Index(i)
+		if v.Get("type").String() != "SpreadElement" {
+			key, value := property(v)
+			group = append(group, fmt.Sprintf("%s: %s", key, value))
+			continue
+		}
+		if len(group) > 0 {
+			args = append(args, fmt.Sprintf("map[string]interface{}{%s}", strings.Join(group, ", ")))
+		}
+		group = nil
+		args = append(args, p.parseAs(v.Get("argument"), typeValue))
+	}
+	if len(group) > 0 {
+		args = append(args, fmt.Sprintf("map[string]interface{}{%s}", strings.Join(group, ", ")))
+	}
+	return fmt.Sprintf("js.Global().Get(\"Object\").Call(\"assign\", %s)", strings.Join(args, ", "))
 }
 
 func (p *Parser) parseParams(obj js.Value) []string {
 	console.Call("log", p.indent(), "ParamsArray:", obj)
 	res := []string{}
 	for i := 0; i < obj.Length(); i++ {
-		id := p.parseIdentifier(obj.Index(i))
+		param := obj.Index(i)
+		if param.Get("type").String() == "RestElement" && param.Get("argument").Get("type").String() == "Identifier" {
+			// a variadic parameter, a slice like JS rest arrays
+			id := p.parseIdentifier(param.Get("argument"))
+			p.declare(id, typeArray)
+			res = append(res, fmt.Sprintf("%s ...interface{}", id))
+			continue
+		}
+		if param.Get("type").String() == "AssignmentPattern" && param.Get("left").Get("type").String() == "Identifier" {
+			// the default applies when the argument is undefined
+			id := p.parseIdentifier(param.Get("left"))
+			p.define(id, false)
+			def := ""
+			lines := p.hoisted(func() { def = p.parseAs(param.Get("right"), typeValue) })
+			lines = append([]string{fmt.Sprintf("if %s.IsUndefined() {", id)}, lines...)
+			p.prologue(append(lines, fmt.Sprintf("%s = %s", id, def), "}")...)
+			res = append(res, fmt.Sprintf("%s js.Value", id))
+			continue
+		}
+		if param.Get("type").String() != "Identifier" {
+			// destructured in the prologue of the body
+			arg := p.temp("arg")
+			p.define(arg, false)
+			p.prologue(p.parseBinding(param, arg, typeValue, false)...)
+			res = append(res, fmt.Sprintf("%s js.Value", arg))
+			continue
+		}
+		id := p.parseIdentifier(param)
 		p.define(id, false)
 		res = append(res, fmt.Sprintf("%s js.Value", id))
 	}
-	return res
+	return res
+}
+
+// prologue appends lines to the prologue of the body of the function
+// being translated. The lines are rendered first, as that may grow the
+// stack and move the frame of the function.
+func (p *Parser) prologue(lines ...string) {
+	fn := p.function()
+	fn.prefix = append(fn.prefix, lines...)
+}
+
+// parseBinding renders the binding of the identifiers of the pattern obj
+// to the parts of value, a Go expression of type tp. The identifiers are
+// declared, or assigned with assign set.
+func (p *Parser) parseBinding(obj js.Value, value, tp string, assign bool) []string {
+	res := []string{}
+	switch obj.Get("type").String() {
+	case "ObjectPattern", "ArrayPattern":
+		src := value
+		if tp != typeValue || !isIdentifier(value) {
+			src = p.temp("src")
+			p.declare(src, typeValue)
+			if tp != typeValue {
+				value = fmt.Sprintf("js.ValueOf(%s)", value)
+			}
+			res = append(res, p.bind(src, value))
+		}
+		if obj.Get("type").String() == "ArrayPattern" {
+			elements := obj.Get("elements")
+			for i := 0; i < elements.Length(); i++ {
+				switch v := elements.Index(i); {
+				case v.IsNull():
+				case v.Get("type").String() == "RestElement":
+					res = append(res, p.parseBinding(v.Get("argument"),
+						fmt.Sprintf("%s.Call(\"slice\", %d)", src, i), typeValue, assign)...)
+				default:
+					res = append(res, p.parseBinding(v, fmt.Sprintf("%s.Index(%d)", src, i), typeValue, assign)...)
+				}
+			}
+			return res
+		}
+		keys := []string{}
+		props := obj.Get("properties")
+		for i := 0; i < props.Length(); i++ {
+			prop := props.Index(i)
+			if prop.Get("type").String() == "RestElement" {
+				// the rest is a copy without the keys bound before
+				rest := p.temp("rest")
+				p.declare(rest, typeValue)
+				res = append(res, p.bind(rest,
+					fmt.Sprintf("js.Global().Get(\"Object\").Call(\"assign\", map[string]interface{}{}, %s)", src)))
+				for _, key := range keys {
+					res = append(res, fmt.Sprintf("%s.Delete(%s)", rest, key))
+				}
+				res = append(res, p.parseBinding(prop.Get("argument"), rest, typeValue, assign)...)
+				continue
+			}
+			key := prop.Get("key")
+			name := ""
+			if key.Get("type").String() == "Identifier" && !prop.Get("computed").Bool() {
+				name = fmt.Sprintf("%q", p.parseIdentifier(key))
+			} else {
+				name = p.parseAs(key, typeString)
+			}
+			keys = append(keys, name)
+			res = append(res, p.parseBinding(prop.Get("value"), fmt.Sprintf("%s.Get(%s)", src, name), typeValue, assign)...)
+		}
+		return res
+	case "AssignmentPattern":
+		// the default applies when the value is undefined
+		left := obj.Get("left")
+		v := p.temp("v")
+		if left.Get("type").String() == "Identifier" && !assign {
+			v = p.parseIdentifier(left)
+		}
+		p.declare(v, typeValue)
+		if tp != typeValue {
+			value = fmt.Sprintf("js.ValueOf(%s)", value)
+		}
+		def := ""
+		lines := p.hoisted(func() { def = p.parseAs(obj.Get("right"), typeValue) })
+		res = append(res, p.bind(v, value), fmt.Sprintf("if %s.IsUndefined() {", v))
+		res = append(append(res, lines...), fmt.Sprintf("%s = %s", v, def), "}")
+		if v == p.parseIdentifier(left) {
+			return res
+		}
+		return append(res, p.parseBinding(left, v, typeValue, assign)...)
+	case "Identifier":
+		name := p.parseIdentifier(obj)
+		switch {
+		case !assign:
+			p.declare(name, tp)
+			return []string{p.bind(name, value)}
+		case p.defined(name) && p.typeOfSymbol(name) == tp:
+			return []string{fmt.Sprintf("%s = %s", name, value)}
+		}
+	}
+	if !assign {
+		p.err = fmt.Errorf("unsupported binding target: %s", obj.Get("type").String())
+		return res
+	}
+	// assign through a temporary to convert the value to the target type
+	v := p.temp("v")
+	p.declare(v, tp)
+	res = append(res, p.bind(v, value))
+	return append(res, p.parseAssignmentExpression(js.ValueOf(map[string]interface{}{
+		"type":     "AssignmentExpression",
+		"operator": "=",
+		"left":     obj,
+		"right":    map[string]interface{}{"type": "Identifier", "name": v},
+	}))...)
+}
+
+// parseParallelBinding renders the array pattern obj bound to the array
+// literal init as one parallel declaration or assignment; ok is false
+// unless both hold plain identifiers and values one to one.
+func (p *Parser) parseParallelBinding(obj, init js.Value, assign bool) (res string, ok bool) {
+	if obj.Get("type").String() != "ArrayPattern" || init.Get("type").String() != "ArrayExpression" {
+		return "", false
+	}
+	ids, values := obj.Get("elements"), init.Get("elements")
+	if ids.Length() != values.Length() {
+		return "", false
+	}
+	exprs := []interface{}{}
+	for i := 0; i < ids.Length(); i++ {
+		id, v := ids.Index(i), values.Index(i)
+		if id.IsNull() || v.IsNull() || id.Get("type").String() != "Identifier" ||
+			v.Get("type").String() == "SpreadElement" || p.isNullish(v) {
+			return "", false
+		}
+		exprs = append(exprs, map[string]interface{}{
+			"type":     "AssignmentExpression",
+			"operator": "=",
+			"left":     id,
+			"right":    v,
+		})
+	}
+	if assign {
+		return p.parseParallelAssignment(js.ValueOf(exprs))
+	}
+	names, vals, types := []string{}, []string{}, []string{}
+	for i := 0; i < ids.Length(); i++ {
+		names = append(names, p.parseIdentifier(ids.Index(i)))
+		vals = append(vals, p.parseExpression(values.Index(i)))
+		types = append(types, p.typeOf(values.Index(i)))
+	}
+	for i, name := range names {
+		p.declare(name, types[i])
+	}
+	return p.bind(strings.Join(names, ", "), strings.Join(vals, ", ")), true
+}
+
+// bind renders the declaration of the local sym initialized to value.
+func (p *Parser) bind(sym, value string) string {
+	if len(p.stack) > 1 {
+		return fmt.Sprintf("%s := %s", sym, value)
+	}
+	return fmt.Sprintf("var %s = %s", sym, value)
+}
+
+// isIdentifier reports whether the Go expression expr is an identifier.
+func isIdentifier(expr string) bool {
+	for i, r := range expr {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return expr != ""
+}
+
+// patternNames returns the identifiers bound by the pattern obj.
+func patternNames(obj js.Value) []string {
+	switch obj.Get("type").String() {
+	case "Identifier":
+		return []string{obj.Get("name").String()}
+	case "AssignmentPattern":
+		return patternNames(obj.Get("left"))
+	case "RestElement":
+		return patternNames(obj.Get("argument"))
+	case "ArrayPattern", "ObjectPattern":
+		res := []string{}
+		items := obj.Get("elements")
+		if obj.Get("type").String() == "ObjectPattern" {
+			items = obj.Get("properties")
+		}
+		for i := 0; i < items.Length(); i++ {
+			switch v := items.Index(i); {
+			case v.IsNull():
+			case v.Get("type").String() == "Property":
+				res = append(res, patternNames(v.Get("value"))...)
+			default:
+				res = append(res, patternNames(v)...)
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+func (p *Parser) parseFunctionDeclaration(obj js.Value) []string {
+	console.Call("log", p.indent(), "FunctionDeclaration:", obj)
+	id := p.parseIdentifier(obj.Get("id"))
+	p.define(id, true)
+	p.declareFunc(id, obj)
+	p.push(obj)
+	defer p.pop()
+	params := p.parseArgumentsObject(obj, p.parseParams(obj.Get("params")))
+	p.setResult(p.inferResult(obj))
+	if p.returns == nil {
+		p.returns, p.errors = map[string]string{}, map[string]bool{}
+	}
+	p.returns[id], p.errors[id] = p.function().result, hasErrorResult(obj)
+	res := []string{fmt.Sprintf("func %s(%s)%s {",
+		id,
+		strings.Join(params, ", "),
+		p.results(),
+	)}
+	res = append(res, p.parseFunctionBody(obj)...)
+	res = append(res, "}")
+	return res
+}
+
+func (p *Parser) parseFunctionExpression(obj js.Value) []string {
+	console.Call("log", p.indent(), "FunctionExpression:", obj)
+	if usesThis(obj) {
+		return p.parseCallback(obj)
+	}
+	p.push(obj)
+	defer p.pop()
+	params := p.parseArgumentsObject(obj, p.parseParams(obj.Get("params")))
+	p.setResult(p.inferResult(obj))
+	res := []string{fmt.Sprintf("func(%s)%s {", strings.Join(params, ", "), p.results())}
+	res = append(res, p.parseFunctionBody(obj)...)
+	res = append(res, "}")
+	return res
+}
+
+// funcValue returns the function (a1, ...r) => f(a1, ...r) adapting the
+// Go func f obj names to the arguments JS passes, or obj otherwise.
+func (p *Parser) funcValue(obj js.Value) js.Value {
+	if obj.Get("type").String() != "Identifier" {
+		return obj
+	}
+	sym := p.parseIdentifier(obj)
+	fn, ok := p.funcOf(sym)
+	if !ok {
+		return obj
+	}
+	ident := func(name string) map[string]interface{} {
+		return map[string]interface{}{"type": "Identifier", "name": name}
+	}
+	params, args := []interface{}{}, []interface{}{}
+	for i := 0; i < arity(fn); i++ {
+		a := ident(p.temp("a"))
+		params, args = append(params, a), append(args, a)
+	}
+	if variadic(fn) {
+		r := ident(p.temp("r"))
+		params = append(params, map[string]interface{}{"type": "RestElement", "argument": r})
+		args = append(args, map[string]interface{}{"type": "SpreadElement", "argument": r})
+	}
+	call := map[string]interface{}{"type": "CallExpression", "callee": ident(sym), "arguments": args}
+	stmt := map[string]interface{}{"type": "ReturnStatement", "argument": call}
+	if p.inferResult(fn) == "" && !p.errors[sym] {
+		stmt = map[string]interface{}{"type": "ExpressionStatement", "expression": call}
+	}
+	return js.ValueOf(map[string]interface{}{
+		"type":       "ArrowFunctionExpression",
+		"params":     params,
+		"body":       map[string]interface{}{"type": "BlockStatement", "body": []interface{}{stmt}},
+		"expression": false,
+		"async":      false,
+		"generator":  false,
+	})
+}
+
+// parseCallback renders the function obj as a js.Func for JS to call,
+// with this bound to the receiver JS passes unless obj is an arrow
+// function, which keeps the this of its enclosing function.
+func (p *Parser) parseCallback(obj js.Value) []string {
+	p.push(obj)
+	defer p.pop()
+	this := "_"
+	if obj.Get("type").String() != "ArrowFunctionExpression" {
+		this = "this"
+		p.bindThis(this, typeValue)
+	}
+	p.define("args", true)
+	params := p.parseParams(obj.Get("params"))
+	p.setResult(typeAny)
+	if hasErrorResult(obj) {
+		p.err = fmt.Errorf("unsupported await in callback")
+	}
+	res := []string{fmt.Sprintf("js.FuncOf(func(%s js.Value, args []js.Value) interface{} {", this)}
+	if usesArguments(obj) {
+		p.declare("arguments", typeArray)
+		res = append(res,
+			"arguments := []interface{}{}",
+			"for _, v := range args {",
+			"arguments = append(arguments, v)",
+			"}",
+		)
+	}
+	names, values := []string{}, []string{}
+	for i, v := range params {
+		if param := obj.Get("params").Index(i); param.Get("type").String() == "RestElement" {
+			// the rest parameter collects the remaining arguments
+			rest, j := p.parseIdentifier(param.Get("argument")), p.temp("i")
+			res = append(res,
+				fmt.Sprintf("%s := []interface{}{}", rest),
+				fmt.Sprintf("for %s := %d; %s < len(args); %s++ {", j, i, j, j),
+				fmt.Sprintf("%s = append(%s, args[%s])", rest, rest, j),
+				"}",
+			)
+			params = params[:i]
+			break
+		}
+		// destructured parameters are read by the prologue
+		name := strings.TrimSuffix(v, " js.Value")
+		if uses(obj.Get("body"), name) || obj.Get("params").Index(i).Get("type").String() != "Identifier" {
+			names = append(names, name)
+			values = append(values, fmt.Sprintf("args[%d]", i))
+		}
+	}
+	if len(names) > 0 {
+		// missing arguments read as undefined, the zero js.Value
+		res = append(res,
+			fmt.Sprintf("args = append(args, make([]js.Value, %d)...)", len(params)),
+			fmt.Sprintf("%s := %s", strings.Join(names, ", "), strings.Join(values, ", ")),
+		)
+	}
+	res = append(res, p.parseFunctionBody(obj)...)
+	return append(res, "})")
+}
+
+func (p *Parser) parseArrowFunctionExpression(obj js.Value) []string {
+	console.Call("log", p.indent(), "ArrowFunctionExpression:", obj)
+	p.push(obj)
+	defer p.pop()
+	params := p.parseParams(obj.Get("params"))
+	p.setResult(p.inferResult(obj))
+	res := []string{fmt.Sprintf("func(%s)%s {", strings.Join(params, ", "), p.results())}
+	res = append(res, p.parseFunctionBody(obj)...)
+	res = append(res, "}")
+	return res
+}
+
+// parseMethodDefinition renders the member obj of the class on top of
+// the stack as a Go function: the constructor as NewClass, instance
+// methods with a pointer receiver, getters and setters as Name and
+// SetName and static methods as package functions.
+func (p *Parser) parseMethodDefinition(obj js.Value) []string {
+	console.Call("log", p.indent(), "MethodDefinition:", obj)
+	c := p.classes[p.parseIdentifier(p.stack[len(p.stack)-1].obj.Get("id"))]
+	name := p.parseIdentifier(obj.Get("key"))
+	fn := obj.Get("value")
+	p.push(obj)
+	defer p.pop()
+	p.push(fn)
+	defer p.pop()
+	if !obj.Get("static").Bool() {
+		p.bindThis(c.recv, "*"+c.name)
+	}
+	list := p.parseArgumentsObject(fn, p.parseParams(fn.Get("params")))
+	params := strings.Join(list, ", ")
+	res := []string{}
+	switch kind := obj.Get("kind").String(); {
+	case kind == "constructor":
+		c.params = list
+		res = append(res,
+			fmt.Sprintf("func New%s(%s) *%s {", c.name, params, c.name),
+			fmt.Sprintf("%s := &%s{}", c.recv, c.name),
+		)
+	case obj.Get("static").Bool():
+		p.setResult(p.inferResult(fn))
+		res = append(res, fmt.Sprintf("func %s%s(%s)%s {", c.name, title(name), params, p.results()))
+	case kind == "get":
+		p.setResult(p.inferResult(fn))
+		res = append(res, fmt.Sprintf("func (%s *%s) %s()%s {", c.recv, c.name, title(name), p.results()))
+	case kind == "set":
+		res = append(res, fmt.Sprintf("func (%s *%s) Set%s(%s) {", c.recv, c.name, title(name), params))
+	default:
+		p.setResult(p.inferResult(fn))
+		res = append(res, fmt.Sprintf("func (%s *%s) %s(%s)%s {", c.recv, c.name, name, params, p.results()))
+	}
+	res = append(res, p.parseFunctionBody(fn)...)
+	return append(res, "}")
+}
+
+func (p *Parser) parseSwitchStatement(obj js.Value) []string {
+	console.Call("log", p.indent(), "SwitchStatement:", obj)
+	p.push(obj)
+	defer p.pop()
+	disc, cases := obj.Get("discriminant"), obj.Get("cases")
+	tp := p.typeOf(disc)
+	native := tp == typeString || tp == typeInt || tp == typeFloat
+	for i := 0; i < cases.Length() && native; i++ {
+		test := cases.Index(i).Get("test")
+		if test.IsNull() {
+			continue
+		}
+		switch ct := p.typeOf(test); {
+		case test.Get("type").String() != "Literal":
+			native = false
+		case ct == typeString || tp == typeString:
+			native = ct == tp
+		case ct == typeFloat:
+			native = tp == typeFloat
+		}
+	}
+	// Go rejects duplicate constant cases that JS skips
+	dup, seen := false, map[string]bool{}
+	for i := 0; i < cases.Length() && native; i++ {
+		if test := cases.Index(i).Get("test"); !test.IsNull() {
+			key := js.Global().Call("String", test.Get("value")).String()
+			dup = dup || seen[key]
+			seen[key] = true
+		}
+	}
+	p.hoist(p.parseCaseDeclarations(cases)...)
+	res := []string{}
+	tag := ""
+	switch {
+	case native && !dup:
+		res = append(res, fmt.Sprintf("switch %s {", p.parseExpression(disc)))
+	case native && disc.Get("type").String() == "Identifier",
+		tp == typeValue && disc.Get("type").String() == "Identifier":
+		tag = p.parseExpression(disc)
+		res = append(res, "switch {")
+	case native:
+		tag = p.temp("tag")
+		res = append(res, fmt.Sprintf("switch %s := %s; {", tag, p.parseExpression(disc)))
+	default:
+		tag = p.temp("tag")
+		res = append(res, fmt.Sprintf("switch %s := %s; {", tag, p.parseAs(disc, typeValue)))
+	}
+	labels := []string{}
+	for i := 0; i < cases.Length(); i++ {
+		c := cases.Index(i)
+		test := c.Get("test")
+		if test.IsNull() {
+			res = append(res, "default:")
+			res = append(res, p.parseStatement(c)...)
+			continue
+		}
+		if native && !dup {
+			labels = append(labels, p.parseExpression(test))
+		} else if native {
+			labels = append(labels, fmt.Sprintf("%s == %s", tag, p.parseExpression(test)))
+		} else {
+			labels = append(labels, fmt.Sprintf("%s.Equal(%s)", tag, p.parseAs(test, typeValue)))
+		}
+		if c.Get("consequent").Length() == 0 && i+1 < cases.Length() &&
+			!cases.Index(i+1).Get("test").IsNull() {
+			// empty cases share the clause of the next one
+			continue
+		}
+		res = append(res, fmt.Sprintf("case %s:", strings.Join(labels, ", ")))
+		res = append(res, p.parseStatement(c)...)
+		labels = labels[:0]
+	}
+	res = append(res, "}")
+	return res
+}
+
+// parseCaseDeclarations declares the variables declared by a case of the
+// switch statement on top of the stack and read by another case, and
+// renders their declarations to place above the switch: each Go case
+// clause is a scope of its own.
+func (p *Parser) parseCaseDeclarations(cases js.Value) []string {
+	res := []string{}
+	for i := 0; i < cases.Length(); i++ {
+		body := cases.Index(i).Get("consequent")
+		for j := 0; j < body.Length(); j++ {
+			v := body.Index(j)
+			if v.Get("type").String() != "VariableDeclaration" {
+				continue
+			}
+			decls := v.Get("declarations")
+			for k := 0; k < decls.Length(); k++ {
+				id, init := decls.Index(k).Get("id"), decls.Index(k).Get("init")
+				for _, name := range patternNames(id) {
+					read := false
+					for l := 0; l < cases.Length(); l++ {
+						read = read || l != i && uses(cases.Index(l).Get("consequent"), name)
+					}
+					if !read {
+						continue
+					}
+					tp := typeValue
+					if id.Get("type").String() == "Identifier" && !init.IsNull() {
+						tp = p.varType(name, init, p.scope())
+					}
+					p.declare(name, tp)
+					res = append(res, fmt.Sprintf("var %s %s", name, tp))
+				}
+			}
+		}
+	}
+	return res
+}
+
+func (p *Parser) parseSwitchCase(obj js.Value) []string {
+	console.Call("log", p.indent(), "SwitchCase:", obj)
+	body := obj.Get("consequent")
+	res := p.parseCaseBody(body)
+	cases := p.stack[len(p.stack)-1].obj.Get("cases")
+	if !terminates(body) && !cases.Index(cases.Length()-1).Equal(obj) {
+		// JS cases fall through unless they break
+		res = append(res, "fallthrough")
+	}
+	return res
+}
+
+// parseCaseBody renders the statements of a switch case without the
+// trailing break that Go switches do implicitly.
+func (p *Parser) parseCaseBody(body js.Value) []string {
+	p.stack[len(p.stack)-1].block = true
+	defer func() { p.stack[len(p.stack)-1].block = false }()
+	res := []string{}
+	for i := 0; i < body.Length(); i++ {
+		v := body.Index(i)
+		if i == body.Length()-1 {
+			switch v.Get("type").String() {
+			case "BreakStatement":
+				if v.Get("label").IsNull() {
+					continue
+				}
+			case "BlockStatement":
+				p.push(v)
+				res = append(res, p.parseCaseBody(v.Get("body"))...)
+				p.pop()
+				continue
+			}
+		}
+		if v.Get("type").String() == "VariableDeclaration" && p.caseDeclared(v) {
+			// declared above the switch
+			decls := v.Get("declarations")
+			for k := 0; k < decls.Length(); k++ {
+				if decl := decls.Index(k); !decl.Get("init").IsNull() {
+					res = append(res, p.flush(p.parseExpressionStatement(js.ValueOf(map[string]interface{}{
+						"type":     "AssignmentExpression",
+						"operator": "=",
+						"left":     decl.Get("id"),
+						"right":    decl.Get("init"),
+					})))...)
+				}
+			}
+			continue
+		}
+		res = append(res, p.flush(p.parseStatement(v))...)
+	}
+	return res
+}
+
+// caseDeclared reports whether the variables of the declaration obj in a
+// switch case are declared above the switch by parseCaseDeclarations.
+func (p *Parser) caseDeclared(obj js.Value) bool {
+	top := p.stack[len(p.stack)-1]
+	if top.obj.Get("type").String() != "SwitchStatement" {
+		return false
+	}
+	decls := obj.Get("declarations")
+	for k := 0; k < decls.Length(); k++ {
+		for _, name := range patternNames(decls.Index(k).Get("id")) {
+			if _, ok := top.scope[name]; !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// terminates reports whether the statement list body always leaves the
+// enclosing switch case.
+func terminates(body js.Value) bool {
+	if body.Length() == 0 {
+		return false
+	}
+	return terminal(body.Index(body.Length() - 1))
+}
+
+// terminal reports whether the statement obj always jumps away.
+func terminal(obj js.Value) bool {
+	switch obj.Get("type").String() {
+	case "BreakStatement", "ContinueStatement", "ReturnStatement", "ThrowStatement":
+		return true
+	case "BlockStatement":
+		return terminates(obj.Get("body"))
+	case "IfStatement":
+		return !obj.Get("alternate").IsNull() && terminal(obj.Get("consequent")) && terminal(obj.Get("alternate"))
+	}
+	return false
+}
+
+func (p *Parser) parseWhileStatement(obj js.Value) []string {
+	console.Call("log", p.indent(), "WhileStatement:", obj)
+	p.push(obj)
+	defer p.pop()
+	res, test := []string{"for {"}, ""
+	lines := p.hoisted(func() { test = p.parseCondition(obj.Get("test")) })
+	switch {
+	case len(lines) > 0:
+		// the lines hoisted out of the test run on each iteration
+		res = append(res, lines...)
+		res = append(res, fmt.Sprintf("if !(%s) {", test), "break", "}")
+	case test != "true":
+		res[0] = fmt.Sprintf("for %s {", test)
+	}
+	res = append(res, p.parseBody(obj.Get("body"))...)
+	res = append(res, "}")
+	return res
+}
+
+func (p *Parser) parseDoWhileStatement(obj js.Value) []string {
+	console.Call("log", p.indent(), "DoWhileStatement:", obj)
+	p.push(obj)
+	defer p.pop()
+	body := p.parseBody(obj.Get("body"))
+	if p.continues(obj.Get("body")) {
+		// continue has to reach the test, so evaluate it in the post statement
+		ok := p.temp("ok")
+		test := p.parseLazy(typeBool, func() string { return p.parseCondition(obj.Get("test")) })
+		res := []string{fmt.Sprintf("for %s := true; %s; %s = %s {", ok, ok, ok, test)}
+		res = append(res, body...)
+		return append(res, "}")
+	}
+	res := []string{"for {"}
+	res = append(res, body...)
+	test := ""
+	lines := p.hoisted(func() { test = p.parseNegatedCondition(obj.Get("test")) })
+	res = append(res, lines...)
+	res = append(res,
+		fmt.Sprintf("if %s {", test),
+		"break",
+		"}",
+		"}",
+	)
+	return res
+}
+
+// continues reports whether body holds a continue statement targeting the
+// loop on top of the stack.
+func (p *Parser) continues(body js.Value) bool {
+	label := ""
+	if len(p.stack) > 1 {
+		if parent := p.stack[len(p.stack)-2].obj; parent.Get("type").String() == "LabeledStatement" {
+			label = p.parseIdentifier(parent.Get("label"))
+		}
+	}
+	found := false
+	walk(body, func(obj js.Value) bool {
+		if isFunction(obj) {
+			return false
+		}
+		switch obj.Get("type").String() {
+		case "ContinueStatement":
+			l := obj.Get("label")
+			found = found || l.IsNull() || p.parseIdentifier(l) == label
+		case "ForStatement", "ForInStatement", "ForOfStatement", "WhileStatement", "DoWhileStatement":
+			// an unlabeled continue in here targets the inner loop
+			return label != ""
+		}
+		return true
+	})
+	return found
+}
+
+func (p *Parser) parseLabeledStatement(obj js.Value) []string {
+	console.Call("log", p.indent(), "LabeledStatement:", obj)
+	label := p.parseIdentifier(obj.Get("label"))
+	p.push(obj)
+	body := p.parseStatement(obj.Get("body"))
+	p.pop()
+	used := false
+	walk(obj.Get("body"), func(v js.Value) bool {
+		switch v.Get("type").String() {
+		case "BreakStatement", "ContinueStatement":
+			if l := v.Get("label"); !l.IsNull() && p.parseIdentifier(l) == label {
+				used = true
+			}
+		}
+		return !isFunction(v)
+	})
+	if !used {
+		// Go rejects unused labels
+		return body
+	}
+	switch tp := obj.Get("body").Get("type").String(); tp {
+	case "ForStatement", "ForInStatement", "ForOfStatement", "WhileStatement", "DoWhileStatement",
+		"SwitchStatement":
+	default:
+		p.err = fmt.Errorf("unsupported labeled statement: %s", tp)
+	}
+	return append([]string{label + ":"}, body...)
+}
+
+func (p *Parser) parseForStatement(obj js.Value) []string {
+	console.Call("log", p.indent(), "ForStatement:", obj)
+	p.push(obj)
+	defer p.pop()
+	init, test, update := "", "", ""
+	lines := []string{}
+	if v := obj.Get("init"); !v.IsNull() {
+		init = p.parseForInit(v)
+	}
+	if v := obj.Get("test"); !v.IsNull() {
+		lines = p.hoisted(func() { test = p.parseCondition(v) })
+	}
+	if v := obj.Get("update"); !v.IsNull() {
+		if hoisted := p.hoisted(func() { update = p.parseExpression(v) }); len(hoisted) > 0 {
+			update = strings.Join(append(append([]string{"func() {"}, hoisted...), update, "}()"), "\n")
+		}
+	}
+	body := p.parseBody(obj.Get("body"))
+	if len(lines) > 0 {
+		// the lines hoisted out of the test run on each iteration
+		body = append(append(lines, fmt.Sprintf("if !(%s) {", test), "break", "}"), body...)
+		test = ""
+	}
+	res := []string{}
+	switch {
+	case init == "" && update == "" && test == "":
+		res = append(res, "for {")
+	case init == "" && update == "":
+		res = append(res, fmt.Sprintf("for %s {", test))
+	default:
+		res = append(res, fmt.Sprintf("for %s; %s; %s {", init, test, update))
+	}
+	res = append(res, body...)
+	res = append(res, "}")
+	return res
+}
+
+// parseForInit renders the init clause of a for statement, declaring the
+// loop variables in the scope of the loop.
+func (p *Parser) parseForInit(obj js.Value) string {
+	if obj.Get("type").String() != "VariableDeclaration" {
+		return p.parseExpression(obj)
+	}
+	ids, values := []string{}, []string{}
+	decls := obj.Get("declarations")
+	for i := 0; i < decls.Length(); i++ {
+		decl := decls.Index(i)
+		if decl.Get("id").Get("type").String() != "Identifier" {
+			p.err = fmt.Errorf("unsupported destructuring in for statement initializer")
+			return ""
+		}
+		id := p.parseIdentifier(decl.Get("id"))
+		init := decl.Get("init")
+		if init.IsNull() {
+			p.declare(id, typeValue)
+			ids, values = append(ids, id), append(values, "js.Undefined()")
+			continue
+		}
+		tp := p.varType(id, init, p.stack[len(p.stack)-1].obj)
+		values = append(values, p.parseInit(init, tp))
+		p.declare(id, tp)
+		ids = append(ids, id)
+	}
+	return fmt.Sprintf("%s := %s", strings.Join(ids, ", "), strings.Join(values, ", "))
+}
+
+func (p *Parser) parseForInStatement(obj js.Value) []string {
+	console.Call("log", p.indent(), "ForInStatement:", obj)
+	p.push(obj)
+	defer p.pop()
+	keys, i := p.temp("keys"), p.temp("i")
+	res := []string{fmt.Sprintf("for %s, %s := 0, js.Global().Get(\"Object\").Call(\"keys\", %s); %s < %s.Length(); %s++ {",
+		i, keys, p.parseAs(obj.Get("right"), typeValue), i, keys, i,
+	)}
+	res = append(res, p.parseForLeft(obj.Get("left"), fmt.Sprintf("%s.Index(%s).String()", keys, i), typeString)...)
+	res = append(res, p.parseBody(obj.Get("body"))...)
+	res = append(res, "}")
+	return res
+}
+
+func (p *Parser) parseForOfStatement(obj js.Value) []string {
+	console.Call("log", p.indent(), "ForOfStatement:", obj)
+	p.push(obj)
+	defer p.pop()
+	right := obj.Get("right")
+	res := []string{}
+	switch {
+	case p.typeOf(right) == typeArray:
+		left := obj.Get("left")
+		if left.Get("type").String() == "VariableDeclaration" &&
+			left.Get("declarations").Index(0).Get("id").Get("type").String() == "Identifier" {
+			v := p.parseIdentifier(left.Get("declarations").Index(0).Get("id"))
+			p.declare(v, typeAny)
+			res = append(res, fmt.Sprintf("for _, %s := range %s {", v, p.parseExpression(right)))
+			break
+		}
+		v := p.temp("v")
+		res = append(res, fmt.Sprintf("for _, %s := range %s {", v, p.parseExpression(right)))
+		res = append(res, p.parseForLeft(left, v, typeAny)...)
+	case isArrayLike(right):
+		arr, i := p.temp("arr"), p.temp("i")
+		res = append(res, fmt.Sprintf("for %s, %s := 0, %s; %s < %s.Length(); %s++ {",
+			i, arr, p.parseExpression(right), i, arr, i,
+		))
+		res = append(res, p.parseForLeft(obj.Get("left"), fmt.Sprintf("%s.Index(%s)", arr, i), typeValue)...)
+	default:
+		// iterator protocol for Maps, Sets, generators and other iterables
+		it, step := p.temp("it"), p.temp("step")
+		res = append(res,
+			fmt.Sprintf("%s := %s", it, p.parseAs(right, typeValue)),
+			fmt.Sprintf("%s = js.Global().Get(\"Reflect\").Call(\"get\", %s, js.Global().Get(\"Symbol\").Get(\"iterator\")).Call(\"call\", %s)",
+				it, it, it,
+			),
+			fmt.Sprintf("for %s := %s.Call(\"next\"); !%s.Get(\"done\").Bool(); %s = %s.Call(\"next\") {",
+				step, it, step, step, it,
+			),
+		)
+		res = append(res, p.parseForLeft(obj.Get("left"), step+`.Get("value")`, typeValue)...)
+	}
+	res = append(res, p.parseBody(obj.Get("body"))...)
+	res = append(res, "}")
+	return res
+}
+
+// parseForLeft binds the value of the current for-in/for-of iteration to
+// the loop variable.
+func (p *Parser) parseForLeft(obj js.Value, value, tp string) []string {
+	switch obj.Get("type").String() {
+	case "VariableDeclaration":
+		return p.parseBinding(obj.Get("declarations").Index(0).Get("id"), value, tp, false)
+	case "ObjectPattern", "ArrayPattern":
+		return p.parseBinding(obj, value, tp, true)
+	}
+	return []string{fmt.Sprintf("%s = %s", p.parseExpression(obj), value)}
+}
+
+// arrayMethods are the methods known to return array-like values.
+var arrayMethods = map[string]bool{
+	"concat":                 true,
+	"entries":                true,
+	"filter":                 true,
+	"flat":                   true,
+	"flatMap":                true,
+	"from":                   true,
+	"getElementsByClassName": true,
+	"getElementsByTagName":   true,
+	"keys":                   true,
+	"map":                    true,
+	"of":                     true,
+	"querySelectorAll":       true,
+	"slice":                  true,
+	"split":                  true,
+	"values":                 true,
+}
+
+// isArrayLike reports whether obj is known to evaluate to an array-like
+// value that can be walked with Length and Index.
+func isArrayLike(obj js.Value) bool {
+	if obj.Get("type").String() != "CallExpression" {
+		return false
+	}
+	callee := obj.Get("callee")
+	if callee.Get("type").String() != "MemberExpression" || callee.Get("computed").Bool() {
+		return false
+	}
+	name := callee.Get("property").Get("name").String()
+	switch name {
+	case "keys", "values", "entries":
+		// Map and Set return iterators, Object returns arrays
+		target := callee.Get("object")
+		return target.Get("type").String() == "Identifier" && target.Get("name").String() == "Object"
+	}
+	return arrayMethods[name]
+}
+
+func (p *Parser) parseIfStatement(obj js.Value) []string {
+	console.Call("log", p.indent(), "IfStatement:", obj)
+	p.push(obj)
+	defer p.pop()
+	res := []string{fmt.Sprintf("if %s {", p.parseCondition(obj.Get("test")))}
+	res = append(res, p.parseBody(obj.Get("consequent"))...)
+	alternate := obj.Get("alternate")
+	switch {
+	case alternate.IsNull():
+		res = append(res, "}")
+	case alternate.Get("type").String() == "IfStatement":
+		chain := []string{}
+		lines := p.hoisted(func() { chain = p.parseIfStatement(alternate) })
+		if len(lines) > 0 {
+			// the test of else if hoisted lines to run first
+			res = append(res, "} else {")
+			res = append(res, lines...)
+			res = append(res, chain...)
+			return append(res, "}")
+		}
+		res = append(res, "} else "+chain[0])
+		res = append(res, chain[1:]...)
+	default:
+		res = append(res, "} else {")
+		res = append(res, p.parseBody(alternate)...)
+		res = append(res, "}")
+	}
+	return res
+}
+
+func (p *Parser) parseTryStatement(obj js.Value) []string {
+	console.Call("log", p.indent(), "TryStatement:", obj)
+	p.push(obj)
+	handler, finalizer := obj.Get("handler"), obj.Get("finalizer")
+	res := []string{"if err := func() (err error) {"}
+	if !finalizer.IsNull() && handler.IsNull() {
+		res = append(res, "defer func() {")
+		res = append(res, p.parseStatement(finalizer)...)
+		res = append(res, "}()")
+	}
+	res = append(res,
+		"defer func() {",
+		"if r := recover(); r != nil {",
+		"if e, ok := r.(error); ok {",
+		"err = e",
+		"return",
+		"}",
+		"panic(r)",
+		"}",
+		"}()",
+	)
+	res = append(res, p.parseStatement(obj.Get("block"))...)
+	if !terminates(obj.Get("block").Get("body")) {
+		res = append(res, "return nil")
+	}
+	switch {
+	case handler.IsNull():
+		res = append(res, "}(); err != nil {", p.errorReturn("err"), "}")
+	case finalizer.IsNull():
+		res = append(res, "}(); err != nil {")
+		res = append(res, p.parseCatchClause(handler)...)
+		res = append(res, "}")
+	default:
+		res = append(res, "}(); err != nil {")
+		res = append(res, p.parseCatchClause(handler)...)
+		res = append(res, "}")
+		wrapped := []string{"func() {", "defer func() {"}
+		wrapped = append(wrapped, p.parseStatement(finalizer)...)
+		wrapped = append(wrapped, "}()")
+		wrapped = append(wrapped, res...)
+		res = append(wrapped, "}()")
+	}
+	try := p.pop()
+	if len(try.jumps) == 0 {
+		return res
+	}
+	// the jumps out of the closures are taken after them
+	res = append([]string{try.ctl + " := 0"}, res...)
+	if try.ret != "" {
+		tp := p.function().result
+		p.declare(try.ret, tp)
+		res = append([]string{fmt.Sprintf("var %s %s", try.ret, tp)}, res...)
+	}
+	for i, v := range try.jumps {
+		res = append(res, fmt.Sprintf("if %s == %d {", try.ctl, i+1))
+		res = append(res, p.parseStatement(v)...)
+		res = append(res, "}")
+	}
+	return res
+}
+
+// jumpOut returns the index of the frame of the innermost try statement
+// whose closure a jump to the frame satisfying target leaves, or -1.
+func (p *Parser) jumpOut(target func(js.Value) bool) int {
+	for i := len(p.stack) - 1; i > 0; i-- {
+		obj := p.stack[i].obj
+		if target(obj) || isFunction(obj) {
+			return -1
+		}
+		try := p.stack[i-1].obj
+		if try.Get("type").String() != "TryStatement" {
+			continue
+		}
+		switch {
+		case obj.Equal(try.Get("block")),
+			obj.Get("type").String() == "CatchClause" && !try.Get("finalizer").IsNull():
+			return i - 1
+		case obj.Get("type").String() != "CatchClause":
+			p.err = fmt.Errorf("unsupported jump out of finally block")
+			return -1
+		}
+	}
+	return -1
+}
+
+// jumpTarget returns the test of the frame the break or continue
+// statement obj jumps to.
+func (p *Parser) jumpTarget(obj js.Value) func(js.Value) bool {
+	label := obj.Get("label")
+	return func(v js.Value) bool {
+		switch v.Get("type").String() {
+		case "LabeledStatement":
+			return !label.IsNull() && p.parseIdentifier(v.Get("label")) == p.parseIdentifier(label)
+		case "ForStatement", "ForInStatement", "ForOfStatement", "WhileStatement", "DoWhileStatement":
+			return label.IsNull()
+		case "SwitchStatement":
+			return label.IsNull() && obj.Get("type").String() == "BreakStatement"
+		}
+		return false
+	}
+}
+
+// parseJump renders the jump statement obj leaving the closure of the try
+// statement in the frame k as the control code it is taken by after the
+// closure returns.
+func (p *Parser) parseJump(k int, obj js.Value) []string {
+	try := &p.stack[k]
+	if try.ctl == "" {
+		try.ctl = p.temp("ctl")
+	}
+	code := 0
+	for i, v := range try.jumps {
+		if jumpKey(v) == jumpKey(obj) {
+			code = i + 1
+		}
+	}
+	if code == 0 {
+		try.jumps = append(try.jumps, obj)
+		code = len(try.jumps)
+	}
+	if p.stack[k+1].obj.Get("type").String() == "CatchClause" {
+		// the closure running the catch clause before finally
+		return []string{fmt.Sprintf("%s = %d", try.ctl, code), "return"}
+	}
+	return []string{fmt.Sprintf("%s = %d", try.ctl, code), "return nil"}
+}
+
+// jumpKey identifies the target of the jump statement obj.
+func jumpKey(obj js.Value) string {
+	if label := obj.Get("label"); label.Type() == js.TypeObject {
+		return obj.Get("type").String() + " " + label.Get("name").String()
+	}
+	return obj.Get("type").String()
+}
+
+func (p *Parser) parseCatchClause(obj js.Value) []string {
+	console.Call("log", p.indent(), "CatchClause:", obj)
+	p.push(obj)
+	defer p.pop()
+	res := []string{}
+	if param := obj.Get("param"); !param.IsNull() {
+		id := p.parseIdentifier(param)
+		used := false
+		walk(obj.Get("body"), func(v js.Value) bool {
+			used = used || v.Get("type").String() == "Identifier" && p.parseIdentifier(v) == id
+			return true
+		})
+		if used {
+			p.declare(id, typeValue)
+			res = append(res,
+				fmt.Sprintf("%s := js.Global().Get(\"Error\").New(err.Error())", id),
+				"if v, ok := err.(js.Error); ok {",
+				fmt.Sprintf("%s = v.Value", id),
+				"}",
+			)
+		}
+	}
+	return append(res, p.parseStatement(obj.Get("body"))...)
+}
+
+func (p *Parser) parseAssignmentExpression(obj js.Value) []string {
+	console.Call("log", p.indent(), "AssignmentExpression:", obj)
+	op := obj.Get("operator").String()
+	left, right := obj.Get("left"), obj.Get("right")
+	res := []string{}
+	if right.Get("type").String() == "AssignmentExpression" {
+		// a = b = c
+		res = append(res, p.parseAssignmentExpression(right)...)
+		right = right.Get("left")
+	}
+	value := right
+	if op != "=" {
+		if left.Get("type").String() == "MemberExpression" {
+			// the target is read and written
+			left = p.onceMember(left)
+		}
+		value = desugar(op, left, right)
+	}
+	name, tp := "", ""
+	switch left.Get("type").String() {
+	case "Identifier":
+		name = p.parseIdentifier(left)
+		if !p.defined(name) {
+			return append(res, fmt.Sprintf("js.Global().Set(%q, %s)", name, p.parseAs(value, typeAny)))
+		}
+		tp = p.typeOfSymbol(name)
+	case "ObjectPattern", "ArrayPattern":
+		if v, ok := p.parseParallelBinding(left, right, true); ok {
+			return append(res, v)
+		}
+		return append(res, p.parseBinding(left, p.parseExpression(right), p.typeOf(right), true)...)
+	case "MemberExpression":
+		ft, ok := p.field(left)
+		if !ok {
+			return append(res, p.parseMemberAssignment(left, value))
+		}
+		name, tp = p.parseExpression(left), ft
+	default:
+		p.err = fmt.Errorf("unsupported assignment target: %s", left.Get("type").String())
+		return res
+	}
+	if op == "=" && right.Get("type").String() == "ConditionalExpression" && p.function() != nil {
+		return append(res, p.parseConditionalAssignment(name, right, tp)...)
+	}
+	if op != "=" && compound(op, tp, p.typeOf(right)) {
+		return append(res, fmt.Sprintf("%s %s %s", name, op, p.parseAs(right, tp)))
+	}
+	return append(res, fmt.Sprintf("%s = %s", name, p.parseAs(value, tp)))
+}
+
+// onceMember returns the member expression obj with its object and
+// computed key replaced by temporaries hoisted ahead when they could have
+// side effects, for obj to be evaluated more than once.
+func (p *Parser) onceMember(obj js.Value) js.Value {
+	once := func(v js.Value) js.Value {
+		switch v.Get("type").String() {
+		case "Identifier", "ThisExpression", "Literal", "Super":
+			return v
+		}
+		tmp, tp := p.temp("v"), p.typeOf(v)
+		p.hoist(p.bind(tmp, p.parseExpression(v)))
+		p.declare(tmp, tp)
+		return js.ValueOf(map[string]interface{}{"type": "Identifier", "name": tmp})
+	}
+	prop := obj.Get("property")
+	if obj.Get("computed").Bool() {
+		prop = once(prop)
+	}
+	return js.ValueOf(map[string]interface{}{
+		"type":     "MemberExpression",
+		"object":   once(obj.Get("object")),
+		"property": prop,
+		"computed": obj.Get("computed"),
+	})
+}
+
+// desugar returns the expression a op b for the compound assignment
+// a op= b.
+func desugar(op string, left, right js.Value) js.Value {
+	tp := "BinaryExpression"
+	switch op {
+	case "&&=", "||=", "??=":
+		tp = "LogicalExpression"
+	}
+	return js.ValueOf(map[string]interface{}{
+		"type":     tp,
+		"operator": strings.TrimSuffix(op, "="),
+		"left":     left,
+		"right":    right,
+	})
+}
+
+// compound reports whether Go has the compound assignment op for a
+// variable of type tp and a value of type vt.
+func compound(op, tp, vt string) bool {
+	switch op {
+	case "+=":
+		if tp == typeString {
+			return true
+		}
+		fallthrough
+	case "-=", "*=":
+		return tp == typeInt && vt == typeInt || tp == typeFloat && (vt == typeInt || vt == typeFloat)
+	case "%=":
+		return tp == typeInt && vt == typeInt
+	}
+	return false
+}
+
+// parseMemberKey renders the object and the key of the member expression
+// obj; index reports whether the key is a numeric index.
+func (p *Parser) parseMemberKey(obj js.Value) (object, key string, index bool) {
+	target, prop := obj.Get("object"), obj.Get("property")
+	computed, tp := obj.Get("computed").Bool(), p.typeOf(target)
+	// a float key names a property like "0.5" rather than an index, and
+	// Go maps only have string keys
+	index = computed && p.typeOf(prop) == typeInt && tp != typeObject
+	switch {
+	case tp == typeObject, tp == typeArray && (index || !computed && p.parseIdentifier(prop) == "length"):
+		object = p.parseOperand(target)
+	default:
+		object = p.parseOperandAs(target, typeValue)
+	}
+	switch {
+	case !computed:
+		return object, fmt.Sprintf("%q", p.parseIdentifier(prop)), false
+	case index:
+		return object, p.parseExpression(prop), true
+	}
+	return object, p.parseAs(prop, typeString), false
+}
+
+// parseSliceIndex renders the element key of the Go slice object, which
+// is undefined out of range like in JS.
+func (p *Parser) parseSliceIndex(object, key string) string {
+	res := []string{"func() interface{} {"}
+	if !isIdentifier(object) {
+		s := p.temp("s")
+		res = append(res, fmt.Sprintf("%s := %s", s, object))
+		object = s
+	}
+	cond := fmt.Sprintf("%s >= 0 && %s < len(%s)", key, key, object)
+	if _, err := strconv.Atoi(key); err == nil {
+		cond = fmt.Sprintf("%s < len(%s)", key, object)
+	} else if !isIdentifier(key) {
+		i := p.temp("i")
+		cond = fmt.Sprintf("%s := %s; %s >= 0 && %s < len(%s)", i, key, i, i, object)
+		key = i
+	}
+	return strings.Join(append(res,
+		fmt.Sprintf("if %s {", cond),
+		fmt.Sprintf("return %s[%s]", object, key),
+		"}",
+		"return js.Undefined()",
+		"}()",
+	), "\n")
+}
+
+// parseSliceAssignment renders the assignment of value to the element key
+// of the Go slice object, which grows with undefined elements like in JS.
+// The key and the value are evaluated before growing.
+func (p *Parser) parseSliceAssignment(object, key string, value js.Value) string {
+	if _, err := strconv.Atoi(key); err != nil && !isIdentifier(key) {
+		i := p.temp("i")
+		p.hoist(p.bind(i, key))
+		key = i
+	}
+	v := p.parseAs(value, typeAny)
+	switch value.Get("type").String() {
+	case "Identifier", "Literal":
+	default:
+		tmp := p.temp("v")
+		p.hoist(p.bind(tmp, v))
+		v = tmp
+	}
+	p.hoist(strings.Join([]string{
+		fmt.Sprintf("for len(%s) <= %s {", object, key),
+		fmt.Sprintf("%s = append(%s, js.Undefined())", object, object),
+		"}",
+	}, "\n"))
+	return fmt.Sprintf("%s[%s] = %s", object, key, v)
+}
+
+// parseMemberAssignment renders the assignment of value to the member
+// expression obj.
+func (p *Parser) parseMemberAssignment(obj, value js.Value) string {
+	if c, name, static := p.classMember(obj); c != nil {
+		if decl, _ := c.member("set", name); decl != nil && !static {
+			return fmt.Sprintf("%s.Set%s(%s)", p.parseOperand(obj.Get("object")), title(name), p.parseAs(value, typeValue))
+		}
+		p.err = fmt.Errorf("unsupported assignment to %s of class %s", name, c.name)
+		return ""
+	}
+	object, key, index := p.parseMemberKey(obj)
+	switch tp := p.typeOf(obj.Get("object")); {
+	case tp == typeObject:
+		return fmt.Sprintf("%s[%s] = %s", object, key, p.parseAs(value, typeAny))
+	case tp == typeArray && index:
+		return p.parseSliceAssignment(object, key, value)
+	case index:
+		return fmt.Sprintf("%s.SetIndex(%s, %s)", object, key, p.parseAs(value, typeAny))
+	}
+	return fmt.Sprintf("%s.Set(%s, %s)", object, key, p.parseAs(value, typeAny))
+}
+
+// parseClassDeclaration renders the class obj as a Go struct holding the
+// fields the constructor and the methods assign to this, followed by its
+// constructor and methods. A class extending another translated class
+// embeds it; like any embedding, methods of the base keep calling their
+// own methods rather than overrides.
+func (p *Parser) parseClassDeclaration(obj js.Value) []string {
+	console.Call("log", p.indent(), "ClassDeclaration:", obj)
+	if p.function() != nil {
+		p.err = fmt.Errorf("unsupported class declaration inside function")
+		return []string{}
+	}
+	name := p.parseIdentifier(obj.Get("id"))
+	c := &class{
+		name:     name,
+		recv:     receiver(obj),
+		types:    map[string]string{},
+		methods:  map[string]string{},
+		getters:  map[string]string{},
+		setters:  map[string]bool{},
+		statics:  map[string]string{},
+		arity:    map[string]int{},
+		variadic: map[string]bool{},
+	}
+	if p.classes == nil {
+		p.classes = map[string]*class{}
+	}
+	if super := obj.Get("superClass"); !super.IsNull() {
+		if c.base = p.classRef(super); c.base == nil {
+			base := super.Get("type").String()
+			if base == "Identifier" {
+				base = p.parseIdentifier(super)
+			}
+			p.err = fmt.Errorf("unsupported class %s extending %s, only translated classes can be extended",
+				name, base)
+			return []string{}
+		}
+	}
+	p.classes[name] = c
+	p.declare(name, typeClass)
+	p.push(obj)
+	defer p.pop()
+	body := obj.Get("body").Get("body")
+	p.scanClass(c, body)
+	if _, ok := c.arity["constructor"]; !ok && c.base != nil {
+		c.arity["constructor"] = c.base.arity["constructor"]
+		c.variadic["constructor"] = c.base.variadic["constructor"]
+	}
+	res := []string{fmt.Sprintf("type %s struct {", name)}
+	if c.base != nil {
+		res = append(res, "*"+c.base.name)
+	}
+	for _, f := range c.fields {
+		res = append(res, fmt.Sprintf("%s %s", f, c.types[f]))
+	}
+	res = append(res, "}")
+	members := []js.Value{}
+	for i := 0; i < body.Length(); i++ {
+		if m := body.Index(i); m.Get("kind").String() == "constructor" {
+			members = append([]js.Value{m}, members...)
+		} else {
+			members = append(members, m)
+		}
+	}
+	switch {
+	case len(members) > 0 && members[0].Get("kind").String() == "constructor":
+	case c.base != nil:
+		// the default constructor passes its arguments to the base
+		c.params = c.base.params
+		args := []string{}
+		for _, v := range c.params {
+			arg := strings.Fields(v)
+			if strings.HasPrefix(arg[1], "...") {
+				arg[0] += "..."
+			}
+			args = append(args, arg[0])
+		}
+		res = append(res,
+			fmt.Sprintf("func New%s(%s) *%s {", name, strings.Join(c.params, ", "), name),
+			fmt.Sprintf("return &%s{%s: New%s(%s)}", name, c.base.name, c.base.name, strings.Join(args, ", ")),
+			"}",
+		)
+	default:
+		res = append(res,
+			fmt.Sprintf("func New%s() *%s {", name, name),
+			fmt.Sprintf("return &%s{}", name),
+			"}",
+		)
+	}
+	for _, m := range members {
+		res = append(res, p.parseMethodDefinition(m)...)
+	}
+	return res
+}
+
+// scanClass records the members of the class c with the class body on
+// top of the stack: the kinds of its methods, the fields assigned to
+// this, typed by their first assignment with the constructor's first,
+// and the method result types.
+func (p *Parser) scanClass(c *class, body js.Value) {
+	members := []js.Value{}
+	for i := 0; i < body.Length(); i++ {
+		m := body.Index(i)
+		if m.Get("computed").Bool() {
+			p.err = fmt.Errorf("unsupported computed class member")
+			continue
+		}
+		name := p.parseIdentifier(m.Get("key"))
+		c.arity[name], c.variadic[name] = arity(m.Get("value")), variadic(m.Get("value"))
+		switch kind := m.Get("kind").String(); {
+		case kind == "constructor":
+			members = append([]js.Value{m}, members...)
+			continue
+		case m.Get("static").Bool():
+			c.statics[name] = ""
+		case kind == "get":
+			c.getters[name] = ""
+		case kind == "set":
+			c.setters[name] = true
+		default:
+			c.methods[name] = ""
+		}
+		members = append(members, m)
+	}
+	for _, m := range members {
+		if m.Get("static").Bool() {
+			continue
+		}
+		fn := m.Get("value")
+		p.push(m)
+		p.push(fn)
+		p.bindThis(c.recv, "*"+c.name)
+		p.parseParams(fn.Get("params"))
+		p.scanBody(fn.Get("body"), func(v js.Value) {
+			left, right := v.Get("left"), v.Get("right")
+			if v.Get("type").String() != "AssignmentExpression" || v.Get("operator").String() != "=" ||
+				left.Get("type").String() != "MemberExpression" || left.Get("computed").Bool() ||
+				left.Get("object").Get("type").String() != "ThisExpression" {
+				return
+			}
+			name := p.parseIdentifier(left.Get("property"))
+			for _, kind := range []string{"field", "get", "set", "method"} {
+				if decl, _ := c.member(kind, name); decl != nil {
+					return
+				}
+			}
+			tp := p.typeOf(right)
+			if p.isNullish(right) || (tp == typeArray || tp == typeObject) && shared(body, func(v js.Value) bool {
+				return v.Get("type").String() == "MemberExpression" && !v.Get("computed").Bool() &&
+					v.Get("object").Get("type").String() == "ThisExpression" &&
+					p.parseIdentifier(v.Get("property")) == name
+			}) {
+				tp = typeValue
+			}
+			c.fields = append(c.fields, name)
+			c.types[name] = tp
+		})
+		p.pop()
+		p.pop()
+	}
+	for _, m := range members {
+		kind, fn := m.Get("kind").String(), m.Get("value")
+		if kind == "constructor" || kind == "set" {
+			continue
+		}
+		p.push(m)
+		p.push(fn)
+		if !m.Get("static").Bool() {
+			p.bindThis(c.recv, "*"+c.name)
+		}
+		p.parseParams(fn.Get("params"))
+		name, tp := p.parseIdentifier(m.Get("key")), p.inferResult(fn)
+		switch {
+		case m.Get("static").Bool():
+			c.statics[name] = tp
+		case kind == "get":
+			c.getters[name] = tp
+		default:
+			c.methods[name] = tp
+		}
+		p.pop()
+		p.pop()
+	}
+}
+
+// member returns the class declaring the member name of kind "field",
+// "get", "set", "method" or "static" among c and the classes it extends,
+// and the member type.
+func (c *class) member(kind, name string) (*class, string) {
+	for ; c != nil; c = c.base {
+		tp, ok := "", false
+		switch kind {
+		case "field":
+			tp, ok = c.types[name]
+		case "get":
+			tp, ok = c.getters[name]
+		case "set":
+			ok = c.setters[name]
+		case "method":
+			tp, ok = c.methods[name]
+		case "static":
+			tp, ok = c.statics[name]
+		}
+		if ok {
+			return c, tp
+		}
+	}
+	return nil, ""
+}
+
+// receiver returns the receiver name of the methods of the class obj,
+// its lowercased initial unless the class uses that name itself.
+func receiver(obj js.Value) string {
+	if recv := strings.ToLower(obj.Get("id").Get("name").String()[:1]); !uses(obj.Get("body"), recv) {
+		return recv
+	}
+	return "self"
+}
+
+// title returns name with its first letter in upper case.
+func title(name string) string {
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// method returns the class and the definition of the method being
+// translated when the innermost function is a class member.
+func (p *Parser) method() (*class, js.Value) {
+	for i := len(p.stack) - 1; i >= 2; i-- {
+		if !isFunction(p.stack[i].obj) {
+			continue
+		}
+		def, decl := p.stack[i-1].obj, p.stack[i-2].obj
+		if def.Get("type").String() != "MethodDefinition" || decl.Get("type").String() != "ClassDeclaration" {
+			break
+		}
+		return p.classes[p.parseIdentifier(decl.Get("id"))], def
+	}
+	return nil, js.Undefined()
+}
+
+// bindThis binds this in the frame on top of the stack to the Go
+// expression sym of type tp.
+func (p *Parser) bindThis(sym, tp string) {
+	p.declare(sym, tp)
+	p.stack[len(p.stack)-1].this = sym
+}
+
+// this returns the frame binding this for the code being translated;
+// arrow functions see the binding of their enclosing function.
+func (p *Parser) this() *stack {
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		switch obj := p.stack[i].obj; {
+		case p.stack[i].this != "":
+			return &p.stack[i]
+		case isFunction(obj) && obj.Get("type").String() != "ArrowFunctionExpression":
+			return nil
+		}
+	}
+	return nil
+}
+
+// uses reports whether the identifier name occurs in obj.
+func uses(obj js.Value, name string) bool {
+	found := false
+	walk(obj, func(v js.Value) bool {
+		found = found || v.Get("type").String() == "Identifier" && v.Get("name").String() == name
+		return !found
+	})
+	return found
+}
+
+// usesThis reports whether the function obj refers to its own this.
+func usesThis(obj js.Value) bool {
+	return refers(obj, func(v js.Value) bool {
+		return v.Get("type").String() == "ThisExpression"
+	})
+}
+
+// usesArguments reports whether the function obj refers to its own
+// arguments object.
+func usesArguments(obj js.Value) bool {
+	if obj.Get("type").String() == "ArrowFunctionExpression" {
+		return false
+	}
+	return refers(obj, func(v js.Value) bool {
+		return v.Get("type").String() == "Identifier" && v.Get("name").String() == "arguments"
+	})
+}
+
+// refers reports whether a node of the body of the function obj outside
+// nested functions other than arrow functions satisfies fn.
+func refers(obj js.Value, fn func(js.Value) bool) bool {
+	found := false
+	walk(obj.Get("body"), func(v js.Value) bool {
+		found = found || fn(v)
+		return !found && (!isFunction(v) || v.Get("type").String() == "ArrowFunctionExpression")
+	})
+	return found
+}
+
+// arity returns the number of parameters of the function obj before its
+// rest parameter, or 0 when it reads its arguments object so that calls
+// are not padded.
+func arity(obj js.Value) int {
+	if usesArguments(obj) {
+		return 0
+	}
+	params := obj.Get("params")
+	for i := 0; i < params.Length(); i++ {
+		if params.Index(i).Get("type").String() == "RestElement" {
+			return i
+		}
+	}
+	return params.Length()
+}
+
+// variadic reports whether the function obj translates to a variadic Go
+// function, for a rest parameter or its arguments object.
+func variadic(obj js.Value) bool {
+	params := obj.Get("params")
+	return usesArguments(obj) ||
+		params.Length() > 0 && params.Index(params.Length()-1).Get("type").String() == "RestElement"
+}
+
+// parseGoArguments renders the arguments args of a call of a Go function
+// of want parameters, variadic or not, padded with undefined values. The
+// spread arguments of a call of a variadic function have to land on its
+// rest parameter.
+func (p *Parser) parseGoArguments(args js.Value, want int, variadic bool) string {
+	if !variadic || !hasSpread(args) {
+		return pad(p.parseArguments(args, typeValue), args.Length(), want)
+	}
+	fixed, rest := []string{}, []interface{}{}
+	for i := 0; i < args.Length(); i++ {
+		switch v := args.Index(i); {
+		case i >= want:
+			rest = append(rest, v)
+		case v.Get("type").String() == "SpreadElement":
+			p.err = fmt.Errorf("unsupported spread in call of Go function")
+			return ""
+		default:
+			fixed = append(fixed, p.parseAs(v, typeValue))
+		}
+	}
+	return strings.Join(append(fixed, p.parseSpreadList(js.ValueOf(rest))+"..."), ", ")
+}
+
+// pad completes the rendered arguments args of a call passing n values
+// to a Go function of want parameters with undefined values.
+func pad(args string, n, want int) string {
+	for ; n < want; n++ {
+		if args != "" {
+			args += ", "
+		}
+		args += "js.Undefined()"
+	}
+	return args
+}
+
+// parseArgumentsObject replaces the rendered parameters params of the
+// function obj by the arguments parameter when it uses its arguments
+// object, a slice of all the arguments.
+func (p *Parser) parseArgumentsObject(obj js.Value, params []string) []string {
+	if !usesArguments(obj) {
+		return params
+	}
+	for i := 0; i < obj.Get("params").Length(); i++ {
+		if obj.Get("params").Index(i).Get("type").String() == "RestElement" {
+			p.err = fmt.Errorf("unsupported arguments in function with rest parameter")
+			return params
+		}
+	}
+	p.declare("arguments", typeArray)
+	if len(params) == 0 {
+		return []string{"arguments ...interface{}"}
+	}
+	// the named parameters in use are bound from the arguments passed,
+	// left undefined when missing, before their defaults apply
+	names, lines := []string{}, []string{}
+	for i, v := range params {
+		name := strings.TrimSuffix(v, " js.Value")
+		if !usesParam(obj, i) {
+			continue
+		}
+		names = append(names, name)
+		lines = append(lines, fmt.Sprintf("if len(arguments) > %d {", i),
+			fmt.Sprintf("%s = js.ValueOf(arguments[%d])", name, i), "}")
+	}
+	if len(names) > 0 {
+		fn := p.function()
+		fn.prefix = append(append([]string{fmt.Sprintf("var %s js.Value", strings.Join(names, ", "))},
+			lines...), fn.prefix...)
+	}
+	return []string{"arguments ...interface{}"}
+}
+
+// usesParam reports whether the i-th parameter of the function obj is
+// read by its body or by the other parameters, destructured and default
+// parameters always are.
+func usesParam(obj js.Value, i int) bool {
+	params := obj.Get("params")
+	param := params.Index(i)
+	if param.Get("type").String() != "Identifier" {
+		return true
+	}
+	name := param.Get("name").String()
+	for j := 0; j < params.Length(); j++ {
+		if j != i && uses(params.Index(j), name) {
+			return true
+		}
+	}
+	return uses(obj.Get("body"), name)
+}
+
+// constructor returns the class whose constructor is being translated.
+func (p *Parser) constructor() *class {
+	if c, def := p.method(); c != nil && def.Get("kind").String() == "constructor" {
+		return c
+	}
+	return nil
+}
+
+// classRef returns the translated class the identifier obj names.
+func (p *Parser) classRef(obj js.Value) *class {
+	if obj.Get("type").String() != "Identifier" {
+		return nil
+	}
+	if name := p.parseIdentifier(obj); p.typeOfSymbol(name) == typeClass {
+		return p.classes[name]
+	}
+	return nil
+}
+
+// classMember returns the class and the member name read by the member
+// expression obj when its object is a translated class or an instance of
+// one; static reports whether the object is the class.
+func (p *Parser) classMember(obj js.Value) (c *class, name string, static bool) {
+	if obj.Get("computed").Bool() {
+		return nil, "", false
+	}
+	target := obj.Get("object")
+	name = p.parseIdentifier(obj.Get("property"))
+	if c := p.classRef(target); c != nil {
+		return c, name, true
+	}
+	if c := p.classOf(p.typeOf(target)); c != nil {
+		return c, name, false
+	}
+	return nil, "", false
+}
+
+// classOf returns the translated class whose instances have the Go type
+// tp.
+func (p *Parser) classOf(tp string) *class {
+	if strings.HasPrefix(tp, "*") {
+		return p.classes[tp[1:]]
+	}
+	return nil
+}
+
+// super returns the embedded base of the receiver bound to this and the
+// base class, or nil outside of methods of derived classes.
+func (p *Parser) super() (string, *class) {
+	if b := p.this(); b != nil {
+		if c := p.classOf(p.typeOfSymbol(b.this)); c != nil && c.base != nil {
+			return b.this + "." + c.base.name, c.base
+		}
+	}
+	return "", nil
+}
+
+func (p *Parser) parseSuper(obj js.Value) []string {
+	console.Call("log", p.indent(), "Super:", obj)
+	base, c := p.super()
+	if c == nil {
+		p.err = fmt.Errorf("unsupported super outside of derived class method")
+	}
+	return []string{base}
+}
+
+// field returns the Go type of the member expression obj when it is a
+// field of a translated class.
+func (p *Parser) field(obj js.Value) (string, bool) {
+	if obj.Get("type").String() != "MemberExpression" {
+		return "", false
+	}
+	if c, name, static := p.classMember(obj); c != nil && !static {
+		decl, tp := c.member("field", name)
+		return tp, decl != nil
+	}
+	return "", false
+}
+
+// parseClassMember renders the read of the member name of the class c by
+// the member expression obj.
+func (p *Parser) parseClassMember(obj js.Value, c *class, name string, static bool) string {
+	if static {
+		if decl, _ := c.member("static", name); decl != nil {
+			return decl.name + title(name)
+		}
+	} else {
+		object := p.parseOperand(obj.Get("object"))
+		if decl, _ := c.member("field", name); decl != nil {
+			return object + "." + name
+		}
+		if decl, _ := c.member("get", name); decl != nil {
+			return object + "." + title(name) + "()"
+		}
+		if decl, _ := c.member("method", name); decl != nil {
+			return object + "." + name
+		}
+	}
+	p.err = fmt.Errorf("unknown member %s of class %s", name, c.name)
+	return name
+}
+
+func (p *Parser) parseAwaitExpression(obj js.Value) []string {
+	console.Call("log", p.indent(), "AwaitExpression:", obj)
+	await := p.await(obj)
+	if top := p.stack[len(p.stack)-1].obj; top.Get("type").String() == "ExpressionStatement" &&
+		top.Get("expression").Equal(obj) {
+		if strings.HasPrefix(await, "js.Undefined(), ") {
+			return []string{
+				fmt.Sprintf("if err := %s; err != nil {", strings.TrimPrefix(await, "js.Undefined(), ")),
+				p.errorReturn("err"),
+				"}",
+			}
+		}
+		return []string{
+			fmt.Sprintf("if _, err := %s; err != nil {", await),
+			p.errorReturn("err"),
+			"}",
+		}
+	}
+	// the result is checked for the error ahead, like a declaration
+	v := p.temp("v")
+	p.hoist(append([]string{fmt.Sprintf("%s, err := %s", v, await)}, p.checkError("err")...)...)
+	return []string{v}
+}
+
+// await renders the await expression obj as the result and the error:
+// the call of jsutil.Await, or the call of a translated function with an
+// error result itself.
+func (p *Parser) await(obj js.Value) string {
+	arg := obj.Get("argument")
+	switch {
+	case !p.isAsyncCall(arg):
+		return fmt.Sprintf("jsutil.Await(%s)", p.parseAs(arg, typeValue))
+	case p.returns[p.parseIdentifier(arg.Get("callee"))] == "":
+		// undefined is the result of a function without one
+		return "js.Undefined(), " + p.parseFunctionCall(arg)
+	}
+	return p.parseFunctionCall(arg)
+}
+
+// checkError renders the propagation of a non-nil err.
+func (p *Parser) checkError(err string) []string {
+	return []string{
+		fmt.Sprintf("if %s != nil {", err),
+		p.errorReturn(err),
+		"}",
+	}
+}
+
+// errorReturn renders the statement raising the Go error err in the
+// context on top of the stack.
+func (p *Parser) errorReturn(err string) string {
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		frame := p.stack[i].obj
+		if isFunction(frame) {
+			if hasErrorResult(frame) {
+				if tp := p.stack[i].result; tp != "" {
+					return fmt.Sprintf("return %s, %s", zero(tp), err)
+				}
+				return fmt.Sprintf("return %s", err)
+			}
+			break
+		}
+		if frame.Get("type").String() != "TryStatement" || i+1 == len(p.stack) {
+			continue
+		}
+		child := p.stack[i+1].obj
+		if child.Equal(frame.Get("block")) {
+			// inside the closure of a try block
+			return fmt.Sprintf("return %s", err)
+		}
+		if child.Get("type").String() != "CatchClause" || !frame.Get("finalizer").IsNull() {
+			// inside a closure deferred by finally
+			break
+		}
+	}
+	return fmt.Sprintf("panic(%s)", err)
+}
+
+// isFunction reports whether obj is a function node.
+func isFunction(obj js.Value) bool {
+	switch obj.Get("type").String() {
+	case "FunctionDeclaration", "FunctionExpression", "ArrowFunctionExpression":
+		return true
+	}
+	return false
+}
+
+// hasErrorResult reports whether the translation of the function obj
+// returns an error, which is the case for async functions awaiting
+// promises.
+func hasErrorResult(obj js.Value) bool {
+	if !obj.Get("async").Bool() {
+		return false
+	}
+	found := false
+	walk(obj.Get("body"), func(v js.Value) bool {
+		found = found || v.Get("type").String() == "AwaitExpression"
+		return !isFunction(v)
+	})
+	return found
+}
+
+// function returns the frame of the innermost function being translated.
+func (p *Parser) function() *stack {
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		if isFunction(p.stack[i].obj) {
+			return &p.stack[i]
+		}
+	}
+	return nil
+}
+
+// inferResult infers the Go result type of the function obj from its
+// return statements, or returns "" when it returns no value.
+func (p *Parser) inferResult(obj js.Value) string {
+	body := obj.Get("body")
+	if obj.Get("expression").Bool() {
+		return p.typeOf(body)
+	}
+	types := []string{}
+	p.scanBody(body, func(v js.Value) {
+		if v.Get("type").String() != "ReturnStatement" {
+			return
+		}
+		switch arg := v.Get("argument"); {
+		case arg.IsNull():
+		case p.isNullish(arg):
+			types = append(types, typeValue)
+		default:
+			types = append(types, p.typeOf(arg))
+		}
+	})
+	return unify(types)
 }
 
-func (p *Parser) parseFunctionDeclaration(obj js.Value) []string {
-	console.Call("log", p.indent(), "FunctionDeclaration:", obj)
-	id := p.parseIdentifier(obj.Get("id"))
-	p.define(id, true)
-	p.push(obj)
+// scanBody calls fn for every node of the function body outside nested
+// functions, with the locals declared in a scratch scope so that fn can
+// type expressions.
+func (p *Parser) scanBody(body js.Value, fn func(js.Value)) {
+	p.push(body)
 	defer p.pop()
-	params := p.parseParams(obj.Get("params"))
-	res := []string{fmt.Sprintf("func %s(%s) {",
-		id,
-		strings.Join(params, ", "),
-	)}
-	res = append(res, p.parseStatement(obj.Get("body"))...)
-	res = append(res, "}")
-	return res
+	walk(body, func(v js.Value) bool {
+		switch v.Get("type").String() {
+		case "ForInStatement", "ForOfStatement":
+			if left := v.Get("left"); left.Get("type").String() == "VariableDeclaration" {
+				tp := typeValue
+				if v.Get("type").String() == "ForInStatement" {
+					tp = typeString
+				}
+				for _, name := range patternNames(left.Get("declarations").Index(0).Get("id")) {
+					p.declare(name, tp)
+				}
+			}
+		case "VariableDeclarator":
+			id, init := v.Get("id"), v.Get("init")
+			if id.Get("type").String() != "Identifier" {
+				for _, name := range patternNames(id) {
+					p.declare(name, typeValue)
+				}
+				break
+			}
+			if !init.IsNull() {
+				p.declare(p.parseIdentifier(id), p.varType(p.parseIdentifier(id), init, body))
+			} else if !p.defined(p.parseIdentifier(id)) {
+				p.declare(p.parseIdentifier(id), typeValue)
+			}
+		}
+		fn(v)
+		return !isFunction(v)
+	})
 }
 
-func (p *Parser) parseFunctionExpression(obj js.Value) []string {
-	console.Call("log", p.indent(), "FunctionExpression:", obj)
-	p.push(obj)
-	defer p.pop()
-	params := p.parseParams(obj.Get("params"))
-	res := []string{fmt.Sprintf("func(%s) {", strings.Join(params, ", "))}
-	res = append(res, p.parseStatement(obj.Get("body"))...)
-	res = append(res, "}")
+// unify returns the Go type able to hold the values of all the types.
+func unify(types []string) string {
+	res := ""
+	for _, tp := range types {
+		switch {
+		case res == "" || res == tp:
+			res = tp
+		case (res == typeInt || res == typeFloat) && (tp == typeInt || tp == typeFloat):
+			res = typeFloat
+		default:
+			return typeAny
+		}
+	}
 	return res
 }
 
-func (p *Parser) parseArrowFunctionExpression(obj js.Value) []string {
-	console.Call("log", p.indent(), "ArrowFunctionExpression:", obj)
-	p.push(obj)
-	defer p.pop()
-	params := p.parseParams(obj.Get("params"))
-	res := []string{fmt.Sprintf("func(%s) {", strings.Join(params, ", "))}
-	res = append(res, p.parseStatement(obj.Get("body"))...)
-	res = append(res, "}")
-	return res
+// zero renders the zero value of the Go type tp.
+func zero(tp string) string {
+	switch tp {
+	case typeValue:
+		return "js.Undefined()"
+	case typeString:
+		return `""`
+	case typeInt, typeFloat:
+		return "0"
+	case typeBool:
+		return "false"
+	}
+	return "nil"
 }
 
-func (p *Parser) parseMethodDefinition(obj js.Value) []string {
-	console.Call("log", p.indent(), "MethodDefinition:", obj)
-	p.push(obj)
-	defer p.pop()
-	p.parseFunctionDeclaration(obj.Get("value"))
-	res := []string{}
-	return res
+// results renders the result list of the function on top of the stack.
+func (p *Parser) results() string {
+	fn := p.function()
+	switch {
+	case fn.result != "" && hasErrorResult(fn.obj):
+		return fmt.Sprintf(" (%s, error)", fn.result)
+	case fn.result != "":
+		return " " + fn.result
+	case hasErrorResult(fn.obj):
+		return " error"
+	}
+	return ""
 }
 
-func (p *Parser) parseSwitchStatement(obj js.Value) []string {
-	console.Call("log", p.indent(), "SwitchStatement:", obj)
-	p.push(obj)
-	defer p.pop()
-	p.parseArray(obj.Get("cases"))
-	res := []string{}
+// parseFunctionBody renders the body of the function obj.
+func (p *Parser) parseFunctionBody(obj js.Value) []string {
+	body := obj.Get("body")
+	result := p.function().result
+	if obj.Get("expression").Bool() {
+		// the prologue destructuring parameters is flushed first
+		p.stack[len(p.stack)-1].block = true
+		if result == "" {
+			return p.flush(p.parseExpressionStatement(body))
+		}
+		if result == typeAny && p.typeOf(body) == "" {
+			// a callback calling a Go function without result
+			return p.flush(append(p.parseExpressionStatement(body), "return nil"))
+		}
+		return p.flush([]string{"return " + p.parseAs(body, result)})
+	}
+	fn := p.function()
+	res := append(fn.prefix, p.parseStatement(body)...)
+	fn.prefix = nil
+	if terminates(body.Get("body")) {
+		return res
+	}
+	if c := p.constructor(); c != nil {
+		return append(res, "return "+c.recv)
+	}
+	switch {
+	case result != "" && hasErrorResult(obj):
+		res = append(res, fmt.Sprintf("return %s, nil", zero(result)))
+	case result != "":
+		res = append(res, "return "+zero(result))
+	case hasErrorResult(obj):
+		res = append(res, "return nil")
+	}
 	return res
 }
 
-func (p *Parser) parseSwitchCase(obj js.Value) []string {
-	console.Call("log", p.indent(), "SwitchCase:", obj)
-	res := []string{}
-	return res
+func (p *Parser) parseBinaryExpression(obj js.Value) []string {
+	console.Call("log", p.indent(), "BinaryExpression:", obj)
+	op := obj.Get("operator").String()
+	left, right := obj.Get("left"), obj.Get("right")
+	lt, rt := p.typeOf(left), p.typeOf(right)
+	binary := func(tp, op string) []string {
+		return []string{fmt.Sprintf("%s %s %s", p.parseOperandAs(left, tp), op, p.parseOperandAs(right, tp))}
+	}
+	switch op {
+	case "+":
+		return binary(p.typeOf(obj), op)
+	case "-", "*", "%":
+		return binary(numeric(lt, rt), op)
+	case "/":
+		if lt == typeInt && rt == typeInt && isNumericLiteral(left) && isNumericLiteral(right) {
+			// untyped integer constants divide as integers in Go
+			return []string{fmt.Sprintf("float64(%s) / %s", p.parseExpression(left), p.parseOperand(right))}
+		}
+		return binary(typeFloat, op)
+	case "**":
+		return []string{fmt.Sprintf("math.Pow(%s, %s)",
+			p.parseAs(left, typeFloat), p.parseAs(right, typeFloat))}
+	case "&", "|", "^", "<<", ">>", ">>>":
+		if v, ok := constNumber(obj); ok {
+			// Go rejects constants overflowing the conversions below
+			return []string{strconv.FormatFloat(v, 'f', -1, 64)}
+		}
+		return []string{p.parseBitwise(op, left, right)}
+	case "<", ">", "<=", ">=":
+		switch {
+		case lt == typeString && rt == typeString:
+			return binary(typeString, op)
+		case lt == typeString && rt == typeValue, lt == typeValue && rt == typeString:
+			return binary(typeString, op)
+		case lt == typeInt && rt == typeValue && isLength(right),
+			lt == typeValue && rt == typeInt && isLength(left):
+			// loop bounds such as i < arr.length
+			return binary(typeInt, op)
+		}
+		return binary(numeric(lt, rt), op)
+	case "===", "!==", "==", "!=":
+		eq := op[:2]
+		not := ""
+		if eq == "!=" {
+			not = "!"
+		}
+		loose := len(op) == 2
+		if res, ok := p.parseTypeofComparison(left, right, not); ok {
+			return []string{res}
+		}
+		switch {
+		case p.isNullish(right) && lt == typeValue:
+			left, right = right, left
+			fallthrough
+		case p.isNullish(left) && rt == typeValue:
+			v := p.parseOperand(right)
+			switch {
+			case loose:
+				if not != "" {
+					return []string{fmt.Sprintf("!%s.IsNull() && !%s.IsUndefined()", v, v)}
+				}
+				return []string{fmt.Sprintf("(%s.IsNull() || %s.IsUndefined())", v, v)}
+			case left.Get("type").String() == "Literal":
+				return []string{fmt.Sprintf("%s%s.IsNull()", not, v)}
+			default:
+				return []string{fmt.Sprintf("%s%s.IsUndefined()", not, v)}
+			}
+		case lt == typeObject || lt == typeArray || rt == typeObject || rt == typeArray:
+			// Go slices and maps convert to fresh JS values
+			p.err = fmt.Errorf("unsupported comparison of Go slice or map")
+			return []string{}
+		case lt == rt && lt != typeValue && lt != typeAny:
+			return binary(lt, eq)
+		case (lt == typeInt || lt == typeFloat) && (rt == typeInt || rt == typeFloat):
+			return binary(typeFloat, eq)
+		case loose:
+			return []string{p.parseLooseEquality(left, right, not)}
+		}
+		return []string{fmt.Sprintf("%s%s.Equal(%s)",
+			not, p.parseAs(left, typeValue), p.parseAs(right, typeValue))}
+	case "instanceof":
+		return []string{fmt.Sprintf("%s.InstanceOf(%s)",
+			p.parseAs(left, typeValue), p.parseAs(right, typeValue))}
+	case "in":
+		return []string{fmt.Sprintf("js.Global().Get(\"Reflect\").Call(\"has\", %s, %s).Bool()",
+			p.parseAs(right, typeValue), p.parseExpression(left))}
+	}
+	p.err = fmt.Errorf("unsupported binary operator: %s", op)
+	return []string{}
 }
 
-func (p *Parser) parseWhileStatement(obj js.Value) []string {
-	console.Call("log", p.indent(), "WhileStatement:", obj)
-	p.push(obj)
-	defer p.pop()
-	p.parseStatement(obj.Get("body"))
-	res := []string{}
-	return res
+// parseBitwise renders the bitwise operation left op right on 32-bit
+// integers like JS: the shift count is taken modulo 32 and the result is
+// wrapped to a signed 32-bit integer, or an unsigned one for >>>.
+func (p *Parser) parseBitwise(op string, left, right js.Value) string {
+	operand := func(v js.Value, tp string) string {
+		if c, ok := constNumber(v); ok {
+			if tp == "uint32" {
+				return strconv.FormatUint(uint64(toUint32(c)), 10)
+			}
+			return strconv.Itoa(int(toInt32(c)))
+		}
+		return p.parseOperandAs(v, typeInt)
+	}
+	count := func() string {
+		if c, ok := constNumber(right); ok {
+			return strconv.Itoa(int(toUint32(c) & 31))
+		}
+		return fmt.Sprintf("(%s & 31)", operand(right, "int32"))
+	}
+	switch op {
+	case "<<":
+		return fmt.Sprintf("int(int32(%s << %s))", operand(left, "int32"), count())
+	case ">>":
+		return fmt.Sprintf("int(int32(%s) >> %s)", operand(left, "int32"), count())
+	case ">>>":
+		return fmt.Sprintf("int(uint32(%s) >> %s)", operand(left, "uint32"), count())
+	}
+	return fmt.Sprintf("int(int32(%s %s %s))", operand(left, "int32"), op, operand(right, "int32"))
 }
 
-func (p *Parser) parseDoWhileStatement(obj js.Value) []string {
-	console.Call("log", p.indent(), "DoWhileStatement:", obj)
-	p.push(obj)
-	defer p.pop()
-	p.parseStatement(obj.Get("body"))
-	res := []string{}
-	return res
+// constNumber evaluates the number expression obj made of literals only.
+func constNumber(obj js.Value) (float64, bool) {
+	switch obj.Get("type").String() {
+	case "Literal":
+		if v := obj.Get("value"); v.Type() == js.TypeNumber {
+			return v.Float(), true
+		}
+	case "UnaryExpression":
+		x, ok := constNumber(obj.Get("argument"))
+		if !ok {
+			break
+		}
+		switch obj.Get("operator").String() {
+		case "-":
+			return -x, true
+		case "+":
+			return x, true
+		case "~":
+			return float64(^toInt32(x)), true
+		}
+	case "BinaryExpression":
+		x, ok := constNumber(obj.Get("left"))
+		y, ok2 := constNumber(obj.Get("right"))
+		if !ok || !ok2 {
+			break
+		}
+		switch obj.Get("operator").String() {
+		case "+":
+			return x + y, true
+		case "-":
+			return x - y, true
+		case "*":
+			return x * y, true
+		case "/":
+			return x / y, true
+		case "%":
+			return math.Mod(x, y), true
+		case "**":
+			return math.Pow(x, y), true
+		case "&":
+			return float64(toInt32(x) & toInt32(y)), true
+		case "|":
+			return float64(toInt32(x) | toInt32(y)), true
+		case "^":
+			return float64(toInt32(x) ^ toInt32(y)), true
+		case "<<":
+			return float64(toInt32(x) << (toUint32(y) & 31)), true
+		case ">>":
+			return float64(toInt32(x) >> (toUint32(y) & 31)), true
+		case ">>>":
+			return float64(toUint32(x) >> (toUint32(y) & 31)), true
+		}
+	}
+	return 0, false
 }
 
-func (p *Parser) parseForStatement(obj js.Value) []string {
-	console.Call("log", p.indent(), "ForStatement:", obj)
-	p.push(obj)
-	defer p.pop()
-	p.parseStatement(obj.Get("body"))
-	res := []string{}
-	return res
+// toInt32 converts f like the ToInt32 operation of JS.
+func toInt32(f float64) int32 {
+	return int32(toUint32(f))
 }
 
-func (p *Parser) parseForInStatement(obj js.Value) []string {
-	console.Call("log", p.indent(), "ForInStatement:", obj)
-	p.push(obj)
-	defer p.pop()
-	p.parseStatement(obj.Get("body"))
-	res := []string{}
-	return res
+// toUint32 converts f like the ToUint32 operation of JS.
+func toUint32(f float64) uint32 {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0
+	}
+	return uint32(int64(math.Mod(math.Trunc(f), 1<<32)))
 }
 
-func (p *Parser) parseIfStatement(obj js.Value) []string {
-	console.Call("log", p.indent(), "IfStatement:", obj)
-	p.push(obj)
-	defer p.pop()
-	p.parseStatement(obj.Get("consequent"))
-	res := []string{}
-	return res
+// parseLooseEquality renders the JS abstract equality left == right, or
+// its negation with not set. Null and undefined equal each other only,
+// objects compare as their string unless both are objects, and other
+// values of different types compare as numbers.
+func (p *Parser) parseLooseEquality(left, right js.Value, not string) string {
+	lt, rt := p.typeOf(left), p.typeOf(right)
+	if rt == typeValue && lt != typeValue && lt != typeAny {
+		left, right, lt, rt = right, left, rt, lt
+	}
+	switch {
+	case lt == typeValue && (rt == typeInt || rt == typeFloat || rt == typeBool):
+		v := p.parseOperand(left)
+		if !isIdentifier(v) {
+			tmp := p.temp("v")
+			p.hoist(fmt.Sprintf("%s := %s", tmp, v))
+			v = tmp
+		}
+		if not != "" {
+			return fmt.Sprintf("(%s.IsNull() || js.Global().Call(\"Number\", %s).Float() != %s)", v, v, p.parseNumber(right))
+		}
+		return fmt.Sprintf("!%s.IsNull() && js.Global().Call(\"Number\", %s).Float() == %s", v, v, p.parseNumber(right))
+	case lt != typeValue && lt != typeAny:
+		// Go values of different types
+		eq := "=="
+		if not != "" {
+			eq = "!="
+		}
+		return fmt.Sprintf("%s %s %s", p.parseNumber(left), eq, p.parseNumber(right))
+	}
+	return strings.Join([]string{
+		not + "func(a, b js.Value) bool {",
+		"for {",
+		"switch ta, tb := a.Type(), b.Type(); {",
+		"case ta == tb:",
+		"return a.Equal(b)",
+		"case a.IsNull() || a.IsUndefined() || b.IsNull() || b.IsUndefined():",
+		"return (a.IsNull() || a.IsUndefined()) && (b.IsNull() || b.IsUndefined())",
+		"case ta == js.TypeObject || ta == js.TypeFunction:",
+		"a = js.Global().Call(\"String\", a)",
+		"case tb == js.TypeObject || tb == js.TypeFunction:",
+		"b = js.Global().Call(\"String\", b)",
+		"default:",
+		"return js.Global().Call(\"Number\", a).Float() == js.Global().Call(\"Number\", b).Float()",
+		"}",
+		"}",
+		fmt.Sprintf("}(%s, %s)", p.parseAs(left, typeValue), p.parseAs(right, typeValue)),
+	}, "\n")
 }
 
-func (p *Parser) parseTryStatement(obj js.Value) []string {
-	console.Call("log", p.indent(), "TryStatement:", obj)
-	p.push(obj)
-	defer p.pop()
-	p.parseStatement(obj.Get("block"))
-	res := []string{}
-	return res
+// parseNumber renders obj converted to a float64 like JS converts values
+// to numbers, strings and booleans included.
+func (p *Parser) parseNumber(obj js.Value) string {
+	switch p.typeOf(obj) {
+	case typeValue:
+		return fmt.Sprintf("js.Global().Call(\"Number\", %s).Float()", p.parseExpression(obj))
+	case typeBool:
+		if obj.Get("type").String() == "Literal" {
+			if obj.Get("value").Bool() {
+				return "1"
+			}
+			return "0"
+		}
+	}
+	return p.parseOperandAs(obj, typeFloat)
 }
 
-func (p *Parser) parseAssignmentExpression(obj js.Value) []string {
-	console.Call("log", p.indent(), "AssignmentExpression:", obj)
-	res := []string{}
-	p.parseStatement(obj.Get("left"))
-	p.parseStatement(obj.Get("right"))
-	return res
+// isLength reports whether obj reads the length property of a value.
+func isLength(obj js.Value) bool {
+	return obj.Get("type").String() == "MemberExpression" && !obj.Get("computed").Bool() &&
+		obj.Get("property").Get("name").String() == "length"
 }
 
-func (p *Parser) parseClassDeclaration(obj js.Value) []string {
-	console.Call("log", p.indent(), "ClassDeclaration:", obj)
-	p.push(obj)
-	defer p.pop()
-	p.parseStatement(obj.Get("body"))
-	res := []string{}
-	return res
+// jsTypes maps the results of typeof to the js.Type constants.
+var jsTypes = map[string]string{
+	"boolean":  "js.TypeBoolean",
+	"number":   "js.TypeNumber",
+	"string":   "js.TypeString",
+	"symbol":   "js.TypeSymbol",
+	"object":   "js.TypeObject",
+	"function": "js.TypeFunction",
 }
 
-func (p *Parser) parseAwaitExpression(obj js.Value) []string {
-	console.Call("log", p.indent(), "AwaitExpression:", obj)
-	res := p.parseCallExpression(obj.Get("argument"))
-	return []string{fmt.Sprintf("jsutil.Await(%s)", strings.Join(res, ", "))}
+// parseTypeofComparison renders the comparison of typeof of a js.Value
+// with a type name as a test of its js.Type; ok is false for any other
+// comparison.
+func (p *Parser) parseTypeofComparison(left, right js.Value, not string) (res string, ok bool) {
+	if right.Get("operator").String() == "typeof" {
+		left, right = right, left
+	}
+	if left.Get("type").String() != "UnaryExpression" || left.Get("operator").String() != "typeof" ||
+		right.Get("type").String() != "Literal" || right.Get("value").Type() != js.TypeString {
+		return "", false
+	}
+	arg := left.Get("argument")
+	if tp := p.typeOf(arg); tp != typeValue && tp != typeAny {
+		return "", false
+	}
+	v := p.parseOperandAs(arg, typeValue)
+	eq := "=="
+	if not != "" {
+		eq = "!="
+	}
+	switch name := right.Get("value").String(); {
+	case name == "undefined":
+		return fmt.Sprintf("%s%s.IsUndefined()", not, v), true
+	case name == "object" && arg.Get("type").String() == "Identifier":
+		// typeof null is "object"
+		if not != "" {
+			return fmt.Sprintf("%s.Type() != js.TypeObject && !%s.IsNull()", v, v), true
+		}
+		return fmt.Sprintf("(%s.Type() == js.TypeObject || %s.IsNull())", v, v), true
+	case name != "object" && jsTypes[name] != "":
+		return fmt.Sprintf("%s.Type() %s %s", v, eq, jsTypes[name]), true
+	}
+	return "", false
+}
+
+// parseUnaryExpression renders the unary operators, coercing the operand
+// like JS does.
+func (p *Parser) parseUnaryExpression(obj js.Value) []string {
+	console.Call("log", p.indent(), "UnaryExpression:", obj)
+	arg := obj.Get("argument")
+	top := p.stack[len(p.stack)-1].obj
+	statement := top.Get("type").String() == "ExpressionStatement" && top.Get("expression").Equal(obj)
+	switch op := obj.Get("operator").String(); op {
+	case "!":
+		if arg.Get("type").String() == "UnaryExpression" && arg.Get("operator").String() == "!" {
+			// !!x is the truthiness of x
+			return []string{p.parseCondition(arg.Get("argument"))}
+		}
+		return []string{p.parseNegatedCondition(arg)}
+	case "-", "+":
+		res := p.parseNumber(arg)
+		switch p.typeOf(arg) {
+		case typeInt:
+			res = p.parseOperand(arg)
+		case typeBool:
+			res = p.parseOperandAs(arg, typeFloat)
+		}
+		if op == "+" {
+			return []string{res}
+		}
+		return []string{"-" + res}
+	case "~":
+		return []string{"^" + p.parseOperandAs(arg, typeInt)}
+	case "typeof":
+		switch p.typeOf(arg) {
+		case typeString:
+			return []string{`"string"`}
+		case typeInt, typeFloat:
+			return []string{`"number"`}
+		case typeBool:
+			return []string{`"boolean"`}
+		case typeObject, typeArray:
+			return []string{`"object"`}
+		}
+		return []string{strings.Join([]string{
+			"func() string {",
+			fmt.Sprintf("if t := %s.Type(); t != js.TypeNull {", p.parseOperandAs(arg, typeValue)),
+			"return t.String()",
+			"}",
+			`return "object"`,
+			"}()",
+		}, "\n")}
+	case "void":
+		// evaluate for the side effects only
+		res := []string{}
+		switch arg.Get("type").String() {
+		case "Literal", "Identifier":
+		case "CallExpression", "NewExpression", "AssignmentExpression", "UpdateExpression",
+			"AwaitExpression", "SequenceExpression", "UnaryExpression":
+			res = p.parseExpressionStatement(arg)
+		default:
+			res = []string{"_ = " + p.parseExpression(arg)}
+		}
+		if statement {
+			return res
+		}
+		p.hoist(res...)
+		return []string{"js.Undefined()"}
+	case "delete":
+		if arg.Get("type").String() != "MemberExpression" {
+			p.err = fmt.Errorf("unsupported delete of %s", arg.Get("type").String())
+			return []string{}
+		}
+		object, key, index := p.parseMemberKey(arg)
+		res := ""
+		switch tp := p.typeOf(arg.Get("object")); {
+		case tp == typeObject:
+			res = fmt.Sprintf("delete(%s, %s)", object, key)
+		case tp == typeArray:
+			p.err = fmt.Errorf("unsupported delete of slice element")
+			return []string{}
+		case !statement:
+			return []string{fmt.Sprintf("js.Global().Get(\"Reflect\").Call(\"deleteProperty\", %s, %s).Bool()", object, key)}
+		case index:
+			res = fmt.Sprintf("%s.Delete(fmt.Sprint(%s))", object, key)
+		default:
+			res = fmt.Sprintf("%s.Delete(%s)", object, key)
+		}
+		if statement {
+			return []string{res}
+		}
+		p.hoist(res)
+		return []string{"true"}
+	}
+	p.err = fmt.Errorf("unsupported unary operator: %s", obj.Get("operator").String())
+	return []string{}
+}
+
+func (p *Parser) parseLogicalExpression(obj js.Value) []string {
+	console.Call("log", p.indent(), "LogicalExpression:", obj)
+	op := obj.Get("operator").String()
+	left, right := obj.Get("left"), obj.Get("right")
+	tp := p.typeOf(obj)
+	if tp == typeBool && op != "??" {
+		return []string{p.parseCondition(obj)}
+	}
+	if op == "??" && tp == p.typeOf(left) && tp != typeValue {
+		// a Go value is never null or undefined
+		return []string{p.parseExpression(left)}
+	}
+	if op == "??" && tp == typeValue && isOptionalChain(left) {
+		return []string{p.parseOptionalChain(left, p.parseLazy(tp, func() string { return p.parseAs(right, tp) }))}
+	}
+	cond := truthy("v", tp)
+	switch op {
+	case "&&":
+		cond = falsy("v", tp)
+	case "??":
+		cond = "!v.IsUndefined() && !v.IsNull()"
+	}
+	res := []string{
+		fmt.Sprintf("func() %s {", tp),
+		fmt.Sprintf("if v := %s; %s {", p.parseAs(left, tp), cond),
+		"return v",
+		"}",
+	}
+	value := ""
+	lines := p.hoisted(func() { value = p.parseAs(right, tp) })
+	res = append(append(res, lines...), "return "+value, "}()")
+	return []string{strings.Join(res, "\n")}
 }
 
 func (p *Parser) parseConditionalExpression(obj js.Value) []string {
 	console.Call("log", p.indent(), "ConditionalExpression:", obj)
-	res := []string{}
-	return res
+	tp := p.typeOf(obj)
+	res := []string{
+		fmt.Sprintf("func() %s {", tp),
+		fmt.Sprintf("if %s {", p.parseCondition(obj.Get("test"))),
+	}
+	for _, key := range []string{"consequent", "alternate"} {
+		// the branches keep the lines they hoist
+		value := ""
+		lines := p.hoisted(func() { value = p.parseAs(obj.Get(key), tp) })
+		res = append(append(res, lines...), "return "+value)
+		if key == "consequent" {
+			res = append(res, "}")
+		}
+	}
+	return []string{strings.Join(append(res, "}()"), "\n")}
+}
+
+// parseConditionalAssignment renders the assignment of the conditional
+// expression obj to the variable name of type tp as an if/else chain.
+func (p *Parser) parseConditionalAssignment(name string, obj js.Value, tp string) []string {
+	assign := func(v js.Value) []string {
+		// the branches keep the lines they hoist
+		value := ""
+		lines := p.hoisted(func() { value = p.parseAs(v, tp) })
+		return append(lines, fmt.Sprintf("%s = %s", name, value))
+	}
+	res := []string{fmt.Sprintf("if %s {", p.parseCondition(obj.Get("test")))}
+	res = append(res, assign(obj.Get("consequent"))...)
+	if alternate := obj.Get("alternate"); alternate.Get("type").String() == "ConditionalExpression" {
+		chain := []string{}
+		lines := p.hoisted(func() { chain = p.parseConditionalAssignment(name, alternate, tp) })
+		if len(lines) == 0 {
+			res = append(res, "} else "+chain[0])
+			return append(res, chain[1:]...)
+		}
+		res = append(append(res, "} else {"), lines...)
+		return append(append(res, chain...), "}")
+	}
+	res = append(res, "} else {")
+	res = append(res, assign(obj.Get("alternate"))...)
+	return append(res, "}")
 }
 
 func (p *Parser) parseReturnStatement(obj js.Value) []string {
 	console.Call("log", p.indent(), "ReturnStatement:", obj)
-	res := []string{}
-	return res
+	fn := p.function()
+	if fn == nil {
+		p.err = fmt.Errorf("return outside of function")
+		return []string{}
+	}
+	if k := p.jumpOut(isFunction); k >= 0 {
+		// the result is kept for the return after the closure
+		res, arg := []string{}, obj.Get("argument")
+		if result := fn.result; result != "" && p.constructor() == nil {
+			value := zero(result)
+			if !arg.IsNull() {
+				value = p.parseAs(arg, result)
+			}
+			if p.stack[k].ret == "" {
+				p.stack[k].ret = p.temp("ret")
+			}
+			res = append(res, fmt.Sprintf("%s = %s", p.stack[k].ret, value))
+			arg = js.ValueOf(map[string]interface{}{"type": "Identifier", "name": p.stack[k].ret})
+		} else {
+			arg = js.Null()
+		}
+		return append(res, p.parseJump(k, js.ValueOf(map[string]interface{}{
+			"type":     "ReturnStatement",
+			"argument": arg,
+		}))...)
+	}
+	if c := p.constructor(); c != nil {
+		return []string{"return " + c.recv}
+	}
+	values := []string{}
+	switch arg := obj.Get("argument"); {
+	case fn.result == "":
+	case arg.IsNull():
+		values = append(values, zero(fn.result))
+	default:
+		values = append(values, p.parseAs(arg, fn.result))
+	}
+	if hasErrorResult(fn.obj) {
+		values = append(values, "nil")
+	}
+	if len(values) == 0 {
+		return []string{"return"}
+	}
+	return []string{"return " + strings.Join(values, ", ")}
 }
 
 func (p *Parser) parseSequenceExpression(obj js.Value) []string {
 	console.Call("log", p.indent(), "SequenceExpression:", obj)
-	res := []string{}
-	return res
+	exprs := obj.Get("expressions")
+	top := p.stack[len(p.stack)-1].obj
+	switch tp := top.Get("type").String(); {
+	case tp == "ExpressionStatement" && top.Get("expression").Equal(obj):
+		p.stack[len(p.stack)-1].block = true
+		res := []string{}
+		for i := 0; i < exprs.Length(); i++ {
+			res = append(res, p.flush(p.parseExpressionStatement(exprs.Index(i)))...)
+		}
+		return res
+	case tp == "ForStatement" && (top.Get("init").Equal(obj) || top.Get("update").Equal(obj)):
+		if res, ok := p.parseParallelAssignment(exprs); ok {
+			return []string{res}
+		}
+		res := []string{"func() {"}
+		for i := 0; i < exprs.Length(); i++ {
+			res = append(res, p.parseExpressionStatement(exprs.Index(i))...)
+		}
+		return []string{strings.Join(append(res, "}()"), "\n")}
+	}
+	// the expressions before the last one run ahead, within the loop or
+	// the closure of an operand evaluated repeatedly or conditionally
+	for i := 0; i < exprs.Length()-1; i++ {
+		p.hoist(p.parseExpressionStatement(exprs.Index(i))...)
+	}
+	return []string{p.parseExpression(exprs.Index(exprs.Length() - 1))}
+}
+
+// parseExpressionStatement renders the expression obj as a statement.
+func (p *Parser) parseExpressionStatement(obj js.Value) []string {
+	return p.parseStatement(js.ValueOf(map[string]interface{}{
+		"type":       "ExpressionStatement",
+		"expression": obj,
+	}))
+}
+
+// parseParallelAssignment renders a sequence of assignments and updates
+// of locals as one parallel assignment; ok is false if exprs holds any
+// other expression.
+func (p *Parser) parseParallelAssignment(exprs js.Value) (res string, ok bool) {
+	names, values := []string{}, []string{}
+	for i := 0; i < exprs.Length(); i++ {
+		expr := exprs.Index(i)
+		var target, value js.Value
+		switch expr.Get("type").String() {
+		case "UpdateExpression":
+			target = expr.Get("argument")
+			value = desugar(expr.Get("operator").String()[:1]+"=", target,
+				js.ValueOf(map[string]interface{}{"type": "Literal", "value": 1}))
+		case "AssignmentExpression":
+			target, value = expr.Get("left"), expr.Get("right")
+			if op := expr.Get("operator").String(); op != "=" {
+				value = desugar(op, target, value)
+			}
+		default:
+			return "", false
+		}
+		if target.Get("type").String() != "Identifier" || !p.defined(p.parseIdentifier(target)) {
+			return "", false
+		}
+		name := p.parseIdentifier(target)
+		names = append(names, name)
+		values = append(values, p.parseAs(value, p.typeOfSymbol(name)))
+	}
+	return fmt.Sprintf("%s = %s", strings.Join(names, ", "), strings.Join(values, ", ")), true
 }
 
 func (p *Parser) parseThrowStatement(obj js.Value) []string {
 	console.Call("log", p.indent(), "ThrowStatement:", obj)
-	res := []string{}
-	return res
+	value := p.parseAs(obj.Get("argument"), typeValue)
+	return []string{p.errorReturn(fmt.Sprintf("js.Error{Value: %s}", value))}
 }
 
 func (p *Parser) parseUpdateExpression(obj js.Value) []string {
 	console.Call("log", p.indent(), "UpdateExpression:", obj)
-	res := []string{}
-	return res
+	arg := obj.Get("argument")
+	if arg.Get("type").String() == "MemberExpression" {
+		// the target is read and written
+		arg = p.onceMember(arg)
+	}
+	update := ""
+	_, field := p.field(arg)
+	if tp := p.typeOf(arg); (field || arg.Get("type").String() == "Identifier" && p.defined(p.parseIdentifier(arg))) &&
+		(tp == typeInt || tp == typeFloat) {
+		update = p.parseExpression(arg) + obj.Get("operator").String()
+	} else {
+		// x++ reads as x += 1
+		update = p.parseAssignmentExpression(js.ValueOf(map[string]interface{}{
+			"type":     "AssignmentExpression",
+			"operator": obj.Get("operator").String()[:1] + "=",
+			"left":     arg,
+			"right":    map[string]interface{}{"type": "Literal", "value": 1},
+		}))[0]
+	}
+	top := p.stack[len(p.stack)-1].obj
+	switch {
+	case top.Get("type").String() == "ExpressionStatement" && top.Get("expression").Equal(obj),
+		top.Get("type").String() == "ForStatement" && top.Get("update").Equal(obj):
+		return []string{update}
+	case obj.Get("prefix").Bool():
+		p.hoist(update)
+		return []string{p.parseAs(arg, p.typeOf(obj))}
+	}
+	// Go has no postfix expressions, keep the old value in a temporary
+	old := p.temp("old")
+	p.hoist(fmt.Sprintf("%s := %s", old, p.parseAs(arg, p.typeOf(obj))), update)
+	return []string{old}
 }
 
 func (p *Parser) parseContinueStatement(obj js.Value) []string {
 	console.Call("log", p.indent(), "ContinueStatement:", obj)
-	res := []string{}
-	return res
+	if k := p.jumpOut(p.jumpTarget(obj)); k >= 0 {
+		return p.parseJump(k, obj)
+	}
+	if label := obj.Get("label"); !label.IsNull() {
+		return []string{"continue " + p.parseIdentifier(label)}
+	}
+	return []string{"continue"}
 }
 
 func (p *Parser) parseBreakStatement(obj js.Value) []string {
 	console.Call("log", p.indent(), "BreakStatement:", obj)
-	res := []string{}
-	return res
+	if k := p.jumpOut(p.jumpTarget(obj)); k >= 0 {
+		return p.parseJump(k, obj)
+	}
+	if label := obj.Get("label"); !label.IsNull() {
+		return []string{"break " + p.parseIdentifier(label)}
+	}
+	return []string{"break"}
 }
 
 func (p *Parser) parseNewExpression(obj js.Value) []string {
 	console.Call("log", p.indent(), "NewExpression:", obj)
+	if c := p.classRef(obj.Get("callee")); c != nil {
+		args := obj.Get("arguments")
+		return []string{fmt.Sprintf("New%s(%s)", c.name,
+			p.parseGoArguments(args, c.arity["constructor"], c.variadic["constructor"]))}
+	}
+	return []string{fmt.Sprintf("%s.New(%s)",
+		p.parseOperandAs(obj.Get("callee"), typeValue),
+		p.parseArguments(obj.Get("arguments"), typeAny),
+	)}
+}
+
+// walk calls fn for obj and every node below it in the syntax tree; fn
+// returns false to skip the children of a node.
+func walk(obj js.Value, fn func(js.Value) bool) {
+	if obj.Type() != js.TypeObject {
+		return
+	}
+	if obj.Get("type").Type() == js.TypeString && !fn(obj) {
+		return
+	}
+	keys := js.Global().Get("Object").Call("keys", obj)
+	for i := 0; i < keys.Length(); i++ {
+		walk(obj.Get(keys.Index(i).String()), fn)
+	}
+}
+
+// parseStatements renders the statement list body with the lines hoisted
+// out of each statement in front of it.
+func (p *Parser) parseStatements(body js.Value) []string {
+	p.stack[len(p.stack)-1].block = true
 	res := []string{}
+	for i := 0; i < body.Length(); i++ {
+		res = append(res, p.flush(p.parseStatement(body.Index(i)))...)
+	}
 	return res
 }
 
-func (p *Parser) parseComputedMemberExpression(obj js.Value) []string {
-	console.Call("log", p.indent(), "ComputedMemberExpression:", obj)
+// hoist queues lines to be emitted in front of the statement being
+// translated, for expressions Go only has as statements.
+func (p *Parser) hoist(lines ...string) {
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		if p.stack[i].block {
+			p.stack[i].prefix = append(p.stack[i].prefix, lines...)
+			return
+		}
+	}
+}
+
+// hoisted calls render and returns the lines hoisted meanwhile, for the
+// caller to place where the rendered code is evaluated: operands
+// evaluated conditionally or repeatedly cannot run ahead of the statement.
+func (p *Parser) hoisted(render func()) []string {
+	top := len(p.stack) - 1
+	block, prefix := p.stack[top].block, p.stack[top].prefix
+	p.stack[top].block, p.stack[top].prefix = true, nil
+	render()
+	lines := p.stack[top].prefix
+	p.stack[top].block, p.stack[top].prefix = block, prefix
+	return lines
+}
+
+// parseLazy renders an expression of type tp with render for a place
+// where it is evaluated conditionally, in a closure with the lines it
+// hoists if any.
+func (p *Parser) parseLazy(tp string, render func() string) string {
+	value := ""
+	lines := p.hoisted(func() { value = render() })
+	if len(lines) == 0 {
+		return value
+	}
+	res := append([]string{fmt.Sprintf("func() %s {", tp)}, lines...)
+	return strings.Join(append(res, "return "+value, "}()"), "\n")
+}
+
+// parseBody renders the body of a compound statement; a single statement
+// keeps the lines it hoists in the body.
+func (p *Parser) parseBody(obj js.Value) []string {
+	if obj.Get("type").String() == "BlockStatement" {
+		return p.parseStatement(obj)
+	}
 	res := []string{}
+	lines := p.hoisted(func() { res = p.parseStatement(obj) })
+	return append(lines, res...)
+}
+
+// flush prepends the hoisted lines to the translated statement src.
+func (p *Parser) flush(src []string) []string {
+	top := &p.stack[len(p.stack)-1]
+	res := top.append(src)
+	top.prefix, top.suffix = nil, nil
 	return res
 }
 
@@ -570,12 +4258,7 @@ func (p *Parser) parseStatement(obj js.Value) []string {
 	case "BlockStatement":
 		console.Call("log", p.indent(), "BlockStatement:", obj)
 		p.push(obj)
-		res = append(res, p.parseArray(obj.Get("body"))...)
-		p.pop()
-	case "ClassBody":
-		console.Call("log", p.indent(), "ClassBody:", obj)
-		p.push(obj)
-		res = append(res, p.parseArray(obj.Get("body"))...)
+		res = append(res, p.parseStatements(obj.Get("body"))...)
 		p.pop()
 	case "ExpressionStatement":
 		console.Call("log", p.indent(), "ExpressionStatement:", obj)
@@ -588,14 +4271,25 @@ func (p *Parser) parseStatement(obj js.Value) []string {
 		res = append(res, p.parseVariableDeclaration(obj)...)
 	case "FunctionExpression":
 		res = append(res, p.parseFunctionExpression(obj)...)
+	case "Super":
+		res = append(res, p.parseSuper(obj)...)
+	case "UnaryExpression":
+		res = append(res, p.parseUnaryExpression(obj)...)
+	case "ChainExpression":
+		console.Call("log", p.indent(), "ChainExpression:", obj)
+		res = append(res, p.parseOptionalChain(obj.Get("expression"), ""))
+	case "TemplateLiteral":
+		res = append(res, p.parseTemplateLiteral(obj)...)
+	case "TaggedTemplateExpression":
+		res = append(res, p.parseTaggedTemplateExpression(obj)...)
 	case "ThisExpression":
 		res = append(res, p.parseThisExpression(obj)...)
 	case "ObjectExpression":
 		res = append(res, p.parseObjectExpression(obj)...)
 	case "BinaryExpression":
-		console.Call("log", p.indent(), "BinaryExpression:", obj)
+		res = append(res, p.parseBinaryExpression(obj)...)
 	case "LogicalExpression":
-		console.Call("log", p.indent(), "LogicalExpression:", obj)
+		res = append(res, p.parseLogicalExpression(obj)...)
 	case "ArrayExpression":
 		res = append(res, p.parseArrayExpression(obj)...)
 	case "ThrowStatement":
@@ -618,10 +4312,10 @@ func (p *Parser) parseStatement(obj js.Value) []string {
 		res = append(res, p.parseContinueStatement(obj)...)
 	case "BreakStatement":
 		res = append(res, p.parseBreakStatement(obj)...)
+	case "LabeledStatement":
+		res = append(res, p.parseLabeledStatement(obj)...)
 	case "NewExpression":
 		res = append(res, p.parseNewExpression(obj)...)
-	case "ComputedMemberExpression":
-		res = append(res, p.parseComputedMemberExpression(obj)...)
 	case "MemberExpression":
 		res = append(res, p.parseMemberExpression(obj)...)
 	case "ReturnStatement":
@@ -642,6 +4336,8 @@ func (p *Parser) parseStatement(obj js.Value) []string {
 		res = append(res, p.parseForStatement(obj)...)
 	case "ForInStatement":
 		res = append(res, p.parseForInStatement(obj)...)
+	case "ForOfStatement":
+		res = append(res, p.parseForOfStatement(obj)...)
 	case "IfStatement":
 		res = append(res, p.parseIfStatement(obj)...)
 	case "TryStatement":
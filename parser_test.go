@@ -0,0 +1,1210 @@
+package main
+
+import (
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall/js"
+	"testing"
+)
+
+// TestMain loads esprima and silences the trace of the parser. The tests
+// run under GOOS=js GOARCH=wasm with the go_js_wasm_exec of the Go
+// distribution on the PATH.
+func TestMain(m *testing.M) {
+	wd, err := os.Getwd()
+	if err != nil {
+		log.Fatal(err)
+	}
+	esprima = js.Global().Call("require", filepath.Join(wd, "esprima.js"))
+	console = js.ValueOf(map[string]interface{}{
+		"log": js.FuncOf(func(js.Value, []js.Value) interface{} { return nil }),
+	})
+	log.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+// translate renders the JS program src as formatted Go source.
+func translate(src string) (string, error) {
+	return translateAST(esprima.Call("parseScript", src))
+}
+
+// translateAST renders the parsed program prog as formatted Go source.
+func translateAST(prog js.Value) (string, error) {
+	p := &Parser{}
+	res, err := p.ParseProgram(prog)
+	if err != nil {
+		return "", err
+	}
+	b, err := format.Source([]byte(strings.Join(res, "\n")))
+	if err != nil {
+		return strings.Join(res, "\n"), err
+	}
+	return string(b), nil
+}
+
+var parseTests = []struct {
+	name string
+	js   string
+	want string
+}{
+	{
+		name: "if else chain",
+		js:   `function sign(x) { if (x > 0) { return 1 } else if (x < 0) { return -1 } else { return 0 } }`,
+		want: `func sign(x js.Value) int {
+	if x.Float() > 0 {
+		return 1
+	} else if x.Float() < 0 {
+		return -1
+	} else {
+		return 0
+	}
+}`,
+	},
+	{
+		name: "for loop",
+		js:   `function count(xs) { let n = 0; for (let i = 0; i < xs.length; i++) { if (xs[i]) { n++ } } return n }`,
+		want: `func count(xs js.Value) int {
+	n := 0
+	for i := 0; i < xs.Get("length").Int(); i++ {
+		if xs.Index(i).Truthy() {
+			n++
+		}
+	}
+	return n
+}`,
+	},
+	{
+		name: "do while",
+		js:   `function wait(u) { let i = 0; do { i++ } while (i < u) }`,
+		want: `func wait(u js.Value) {
+	i := 0
+	for {
+		i++
+		if !(float64(i) < u.Float()) {
+			break
+		}
+	}
+}`,
+	},
+	{
+		name: "switch fallthrough",
+		js:   `function sw(x) { switch (x) { case 1: f(); break; case 2: g(); default: h() } }`,
+		want: `func sw(x js.Value) {
+	switch {
+	case x.Equal(js.ValueOf(1)):
+		js.Global().Call("f")
+	case x.Equal(js.ValueOf(2)):
+		js.Global().Call("g")
+		fallthrough
+	default:
+		js.Global().Call("h")
+	}
+}`,
+	},
+	{
+		name: "try catch",
+		js:   `function tr() { try { f() } catch (e) { console.log(e) } }`,
+		want: `func tr() {
+	if err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				if e, ok := r.(error); ok {
+					err = e
+					return
+				}
+				panic(r)
+			}
+		}()
+		js.Global().Call("f")
+		return nil
+	}(); err != nil {
+		e := js.Global().Get("Error").New(err.Error())
+		if v, ok := err.(js.Error); ok {
+			e = v.Value
+		}
+		js.Global().Get("console").Call("log", e)
+	}
+}`,
+	},
+	{
+		name: "integer division",
+		js:   `function half(a) { let h = 1 / 2; return a / 2 }`,
+		want: `func half(a js.Value) float64 {
+	h := float64(1) / 2
+	return a.Float() / 2
+}`,
+	},
+	{
+		name: "concatenation with value",
+		js:   `function suffix(a) { return a + "x" }`,
+		want: `func suffix(a js.Value) string {
+	return js.Global().Call("String", a).String() + "x"
+}`,
+	},
+	{
+		name: "continue out of try",
+		js:   `function skip() { for (let i = 0; i < 3; i++) { try { if (i) continue; g() } catch (e) { break } } }`,
+		want: `func skip() {
+	for i := 0; i < 3; i++ {
+		ctl1 := 0
+		if err := func() (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					if e, ok := r.(error); ok {
+						err = e
+						return
+					}
+					panic(r)
+				}
+			}()
+			if i != 0 {
+				ctl1 = 1
+				return nil
+			}
+			js.Global().Call("g")
+			return nil
+		}(); err != nil {
+			break
+		}
+		if ctl1 == 1 {
+			continue
+		}
+	}
+}`,
+	},
+	{
+		name: "continue out of catch with finally",
+		js:   `function retry() { while (a) { try { x() } catch (e) { continue } finally { c() } } }`,
+		want: `func retry() {
+	for js.Global().Get("a").Truthy() {
+		ctl1 := 0
+		func() {
+			defer func() {
+				js.Global().Call("c")
+			}()
+			if err := func() (err error) {
+				defer func() {
+					if r := recover(); r != nil {
+						if e, ok := r.(error); ok {
+							err = e
+							return
+						}
+						panic(r)
+					}
+				}()
+				js.Global().Call("x")
+				return nil
+			}(); err != nil {
+				ctl1 = 1
+				return
+			}
+		}()
+		if ctl1 == 1 {
+			continue
+		}
+	}
+}`,
+	},
+	{
+		name: "return await",
+		js:   `async function load(u) { let r = await fetch(u); return await r.json() }`,
+		want: `func load(u js.Value) (js.Value, error) {
+	r, err := jsutil.Await(js.Global().Call("fetch", u))
+	if err != nil {
+		return js.Undefined(), err
+	}
+	v1, err := jsutil.Await(r.Call("json"))
+	if err != nil {
+		return js.Undefined(), err
+	}
+	return v1, nil
+}`,
+	},
+	{
+		name: "return out of try",
+		js:   `function parse(s) { try { return JSON.parse(s) } catch (e) {} }`,
+		want: `func parse(s js.Value) js.Value {
+	var ret1 js.Value
+	ctl2 := 0
+	if err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				if e, ok := r.(error); ok {
+					err = e
+					return
+				}
+				panic(r)
+			}
+		}()
+		ret1 = js.Global().Get("JSON").Call("parse", s)
+		ctl2 = 1
+		return nil
+	}(); err != nil {
+	}
+	if ctl2 == 1 {
+		return ret1
+	}
+	return js.Undefined()
+}`,
+	},
+	{
+		name: "call of translated function",
+		js: `function inner(x) { return x * 0.5 }
+function outer() { return inner(3) + inner() }`,
+		want: `func inner(x js.Value) float64 {
+	return x.Float() * 0.5
+}
+func outer() float64 {
+	return inner(js.ValueOf(3)) + inner(js.Undefined())
+}`,
+	},
+	{
+		name: "variable widened to float",
+		js:   `function avg(xs) { let total = 0; for (const x of xs) { total += x } return total / xs.length }`,
+		want: `func avg(xs js.Value) float64 {
+	total := float64(0)
+	it1 := xs
+	it1 = js.Global().Get("Reflect").Call("get", it1, js.Global().Get("Symbol").Get("iterator")).Call("call", it1)
+	for step2 := it1.Call("next"); !step2.Get("done").Bool(); step2 = it1.Call("next") {
+		x := step2.Get("value")
+		total = total + x.Float()
+	}
+	return total / xs.Get("length").Float()
+}`,
+	},
+	{
+		name: "variable widened by closure",
+		js:   `function sum(xs) { let t = 0; xs.forEach(x => { t += x }); return t }`,
+		want: `func sum(xs js.Value) float64 {
+	t := float64(0)
+	xs.Call("forEach", js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		args = append(args, make([]js.Value, 1)...)
+		x := args[0]
+		t = t + x.Float()
+		return nil
+	}))
+	return t
+}`,
+	},
+	{
+		name: "integer counters",
+		js:   `function count() { let n = 0; for (let i = 0; i < 3; i++) { n += i } for (let x = 0; x < 1; x += 0.5) { f(x) } return n }`,
+		want: `func count() int {
+	n := 0
+	for i := 0; i < 3; i++ {
+		n += i
+	}
+	for x := float64(0); x < 1; x += 0.5 {
+		js.Global().Call("f", x)
+	}
+	return n
+}`,
+	},
+	{
+		name: "update in loop test",
+		js:   `function lp() { let i = 10; while (i-- > 0) { f(i) } }`,
+		want: `func lp() {
+	i := 10
+	for {
+		old1 := i
+		i--
+		if !(old1 > 0) {
+			break
+		}
+		js.Global().Call("f", i)
+	}
+}`,
+	},
+	{
+		name: "update in for test",
+		js:   `function fl() { let n = 0; for (let i = 0; i < n++; i++) { f(i) } }`,
+		want: `func fl() {
+	n := 0
+	for i := 0; ; i++ {
+		old1 := n
+		n++
+		if !(i < old1) {
+			break
+		}
+		js.Global().Call("f", i)
+	}
+}`,
+	},
+	{
+		name: "update in short-circuit operand",
+		js:   `function sc(a) { let n = 0; if (a && a[n++]) { g() } return n }`,
+		want: `func sc(a js.Value) int {
+	n := 0
+	if a.Truthy() && func() bool {
+		old1 := n
+		n++
+		return a.Index(old1).Truthy()
+	}() {
+		js.Global().Call("g")
+	}
+	return n
+}`,
+	},
+	{
+		name: "update in conditional branches",
+		js:   `function tern(c) { let n = 0; let x = c ? n++ : 0; return g(c ? ++n : 0) }`,
+		want: `func tern(c js.Value) js.Value {
+	n := 0
+	var x int
+	if c.Truthy() {
+		old1 := n
+		n++
+		x = old1
+	} else {
+		x = 0
+	}
+	return js.Global().Call("g", func() int {
+		if c.Truthy() {
+			n++
+			return n
+		}
+		return 0
+	}())
+}`,
+	},
+	{
+		name: "update in single statement body",
+		js:   `function body(c) { let n = 0; if (c) f(n++); else if (n--) g() }`,
+		want: `func body(c js.Value) {
+	n := 0
+	if c.Truthy() {
+		old1 := n
+		n++
+		js.Global().Call("f", old1)
+	} else {
+		old2 := n
+		n--
+		if old2 != 0 {
+			js.Global().Call("g")
+		}
+	}
+}`,
+	},
+	{
+		name: "array receiving method calls",
+		js:   `function arr() { let xs = []; xs.push(1); let ys = [1, 2]; return xs.length + ys.length }`,
+		want: `func arr() float64 {
+	xs := js.ValueOf([]interface{}{})
+	xs.Call("push", 1)
+	ys := []interface{}{
+		1,
+		2,
+	}
+	return xs.Get("length").Float() + float64(len(ys))
+}`,
+	},
+	{
+		name: "object passed to function",
+		js:   `function obj(a) { let o = {}; Object.assign(o, a); return o }`,
+		want: `func obj(a js.Value) js.Value {
+	o := js.ValueOf(map[string]interface{}{})
+	js.Global().Get("Object").Call("assign", o, a)
+	return o
+}`,
+	},
+	{
+		name: "class field receiving method calls",
+		js:   `class Stack { constructor() { this.items = [] } push(x) { this.items.push(x) } }`,
+		want: `type Stack struct {
+	items js.Value
+}
+
+func NewStack() *Stack {
+	s := &Stack{}
+	s.items = js.ValueOf([]interface{}{})
+	return s
+}
+func (s *Stack) push(x js.Value) {
+	s.items.Call("push", x)
+}`,
+	},
+	{
+		name: "const declarations",
+		js:   `async function load(u, arr) { const n = arr.length; const k = 3; const r = await fetch(u); return n }`,
+		want: `func load(u js.Value, arr js.Value) (js.Value, error) {
+	n := arr.Get("length")
+	const k = 3
+	r, err := jsutil.Await(js.Global().Call("fetch", u))
+	if err != nil {
+		return js.Undefined(), err
+	}
+	return n, nil
+}`,
+	},
+	{
+		name: "sequence in loop test and operand",
+		js:   `function sq(a) { let x; while ((x = next(), x)) { f(x) } if (a && (b(), c)) { g() } }`,
+		want: `func sq(a js.Value) {
+	var x js.Value
+	for {
+		x = js.Global().Call("next")
+		if !(x.Truthy()) {
+			break
+		}
+		js.Global().Call("f", x)
+	}
+	if a.Truthy() && func() bool {
+		js.Global().Call("b")
+		return js.Global().Get("c").Truthy()
+	}() {
+		js.Global().Call("g")
+	}
+}`,
+	},
+	{
+		name: "number values in templates and keys",
+		js:   `function show(o, k, n) { delete o[k]; return ` + "`${n}: ${o[k]}`" + ` }`,
+		want: `func show(o js.Value, k js.Value, n js.Value) string {
+	o.Delete(js.Global().Call("String", k).String())
+	return fmt.Sprintf("%s: %s", js.Global().Call("String", n).String(), js.Global().Call("String", o.Get(js.Global().Call("String", k).String())).String())
+}`,
+	},
+	{
+		name: "function default parameter",
+		js:   `function dflt(cb = () => 1, n = 2) { return cb() }`,
+		want: `func dflt(cb js.Value, n js.Value) js.Value {
+	if cb.IsUndefined() {
+		cb = js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+			return 1
+		}).Value
+	}
+	if n.IsUndefined() {
+		n = js.ValueOf(2)
+	}
+	return cb.Invoke()
+}`,
+	},
+	{
+		name: "arguments length",
+		js:   `function count(a, b = 3) { return arguments.length + b } function main() { count(1) }`,
+		want: `func count(arguments ...interface{}) float64 {
+	var b js.Value
+	if len(arguments) > 1 {
+		b = js.ValueOf(arguments[1])
+	}
+	if b.IsUndefined() {
+		b = js.ValueOf(3)
+	}
+	return float64(len(arguments)) + b.Float()
+}
+func main() {
+	count(js.ValueOf(1))
+}`,
+	},
+	{
+		name: "object literal with functions",
+		js:   `function mk(x) { return { f: (y) => y, g() { return 2 }, "a-b": x } }`,
+		want: `func mk(x js.Value) map[string]interface{} {
+	return map[string]interface{}{
+		"f": js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+			args = append(args, make([]js.Value, 1)...)
+			y := args[0]
+			return y
+		}),
+		"g": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			return 2
+		}),
+		"a-b": x,
+	}
+}`,
+	},
+	{
+		name: "integer literal",
+		js:   `function big(xs) { let n = 1000000; return xs[n] }`,
+		want: `func big(xs js.Value) js.Value {
+	n := 1000000
+	return xs.Index(n)
+}`,
+	},
+	{
+		name: "bitwise on 32-bit integers",
+		js:   `function hash(s) { let h = 0; for (let i = 0; i < s.length; i++) { h = (h << 5) - h + s.charCodeAt(i); h |= 0 } return (h >>> 0) + (1 << 31) + (-1 >>> 0) }`,
+		want: `func hash(s js.Value) int {
+	h := float64(0)
+	for i := 0; i < s.Get("length").Int(); i++ {
+		h = (float64(int(int32(int(h)<<5))) - h) + s.Call("charCodeAt", i).Float()
+		h = float64(int(int32(int(h) | 0)))
+	}
+	return ((int(uint32(int(h)) >> 0)) + (-2147483648)) + (4294967295)
+}`,
+	},
+	{
+		name: "loose equality",
+		js:   `function eq(x, y) { let t = "1"; return [x == true, x != 0, x == y, t == 1] }`,
+		want: `func eq(x js.Value, y js.Value) []interface{} {
+	t := "1"
+	return []interface{}{
+		!x.IsNull() && js.Global().Call("Number", x).Float() == 1,
+		(x.IsNull() || js.Global().Call("Number", x).Float() != 0),
+		func(a, b js.Value) bool {
+			for {
+				switch ta, tb := a.Type(), b.Type(); {
+				case ta == tb:
+					return a.Equal(b)
+				case a.IsNull() || a.IsUndefined() || b.IsNull() || b.IsUndefined():
+					return (a.IsNull() || a.IsUndefined()) && (b.IsNull() || b.IsUndefined())
+				case ta == js.TypeObject || ta == js.TypeFunction:
+					a = js.Global().Call("String", a)
+				case tb == js.TypeObject || tb == js.TypeFunction:
+					b = js.Global().Call("String", b)
+				default:
+					return js.Global().Call("Number", a).Float() == js.Global().Call("Number", b).Float()
+				}
+			}
+		}(x, y),
+		js.Global().Call("Number", t).Float() == 1,
+	}
+}`,
+	},
+	{
+		name: "for loop with value bound",
+		js:   `function loop(n) { for (let i = 0; i < n; i++) { f(i) } }`,
+		want: `func loop(n js.Value) {
+	for i := 0; float64(i) < n.Float(); i++ {
+		js.Global().Call("f", i)
+	}
+}`,
+	},
+	{
+		name: "for in over object",
+		js:   `function keys(o) { for (const k in o) { f(k, o[k]) } }`,
+		want: `func keys(o js.Value) {
+	for i2, keys1 := 0, js.Global().Get("Object").Call("keys", o); i2 < keys1.Length(); i2++ {
+		k := keys1.Index(i2).String()
+		js.Global().Call("f", k, o.Get(k))
+	}
+}`,
+	},
+	{
+		name: "for of over arrays",
+		js:   `function each(xs) { let ys = [1, 2]; for (const y of ys) { f(y) } for (const x of xs.filter(g)) { f(x) } }`,
+		want: `func each(xs js.Value) {
+	ys := []interface{}{
+		1,
+		2,
+	}
+	for _, y := range ys {
+		js.Global().Call("f", y)
+	}
+	for i2, arr1 := 0, xs.Call("filter", js.Global().Get("g")); i2 < arr1.Length(); i2++ {
+		x := arr1.Index(i2)
+		js.Global().Call("f", x)
+	}
+}`,
+	},
+	{
+		name: "labeled break and continue",
+		js:   `function find(rows) { outer: for (let i = 0; i < rows.length; i++) { for (let j = 0; j < 3; j++) { if (rows[i][j]) continue outer; if (j > i) break outer } } }`,
+		want: `func find(rows js.Value) {
+outer:
+	for i := 0; i < rows.Get("length").Int(); i++ {
+		for j := 0; j < 3; j++ {
+			if rows.Index(i).Index(j).Truthy() {
+				continue outer
+			}
+			if j > i {
+				break outer
+			}
+		}
+	}
+}`,
+	},
+	{
+		name: "unused label",
+		js:   `function unused() { loop: while (a) { break } }`,
+		want: `func unused() {
+	for js.Global().Get("a").Truthy() {
+		break
+	}
+}`,
+	},
+	{
+		name: "switch with duplicate cases",
+		js:   `function dup(s) { let n = s.length * 2; switch (n) { case 1: f(); break; case 1: g() } }`,
+		want: `func dup(s js.Value) {
+	n := s.Get("length").Float() * 2
+	switch {
+	case n == 1:
+		js.Global().Call("f")
+	case n == 1:
+		js.Global().Call("g")
+	}
+}`,
+	},
+	{
+		name: "switch case declaration read by another case",
+		js:   `function shared(x) { switch (x) { case 1: let y = 2; case 2: f(y) } }`,
+		want: `func shared(x js.Value) {
+	var y int
+	switch {
+	case x.Equal(js.ValueOf(1)):
+		y = 2
+		fallthrough
+	case x.Equal(js.ValueOf(2)):
+		js.Global().Call("f", y)
+	}
+}`,
+	},
+	{
+		name: "throw as panic",
+		js:   `function check(x) { if (!x) { throw new Error("missing") } }`,
+		want: `func check(x js.Value) {
+	if !x.Truthy() {
+		panic(js.Error{Value: js.Global().Get("Error").New("missing")})
+	}
+}`,
+	},
+	{
+		name: "throw as error return",
+		js:   `async function load(u) { const r = await fetch(u); if (!r.ok) { throw new Error(r.statusText) } return r }`,
+		want: `func load(u js.Value) (js.Value, error) {
+	r, err := jsutil.Await(js.Global().Call("fetch", u))
+	if err != nil {
+		return js.Undefined(), err
+	}
+	if !r.Get("ok").Truthy() {
+		return js.Undefined(), js.Error{Value: js.Global().Get("Error").New(r.Get("statusText"))}
+	}
+	return r, nil
+}`,
+	},
+	{
+		name: "throw inside try",
+		js:   `function guard(f) { try { if (!f) throw "no"; f() } catch (e) { console.log(e) } }`,
+		want: `func guard(f js.Value) {
+	if err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				if e, ok := r.(error); ok {
+					err = e
+					return
+				}
+				panic(r)
+			}
+		}()
+		if !f.Truthy() {
+			return js.Error{Value: js.ValueOf("no")}
+		}
+		f.Invoke()
+		return nil
+	}(); err != nil {
+		e := js.Global().Get("Error").New(err.Error())
+		if v, ok := err.(js.Error); ok {
+			e = v.Value
+		}
+		js.Global().Get("console").Call("log", e)
+	}
+}`,
+	},
+	{
+		name: "await of translated async function",
+		js: `async function a(u) { const r = await fetch(u); return r }
+async function b(u) { const x = await a(u); return x }
+function c(u) { return a(u).then(g) }`,
+		want: `func a(u js.Value) (js.Value, error) {
+	r, err := jsutil.Await(js.Global().Call("fetch", u))
+	if err != nil {
+		return js.Undefined(), err
+	}
+	return r, nil
+}
+func b(u js.Value) (js.Value, error) {
+	x, err := a(u)
+	if err != nil {
+		return js.Undefined(), err
+	}
+	return x, nil
+}
+func c(u js.Value) js.Value {
+	return js.Global().Get("Promise").New(js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		go func() {
+			v, err := a(u)
+			if err != nil {
+				e := js.Global().Get("Error").New(err.Error())
+				if v, ok := err.(js.Error); ok {
+					e = v.Value
+				}
+				args[1].Invoke(e)
+				return
+			}
+			args[0].Invoke(v)
+		}()
+		return nil
+	})).Call("then", js.Global().Get("g"))
+}`,
+	},
+	{
+		name: "compound assignment to member",
+		js:   `function inc(o) { f().n += 1; o[g()] *= 2; o.n++ }`,
+		want: `func inc(o js.Value) {
+	v1 := js.Global().Call("f")
+	v1.Set("n", v1.Get("n").Float()+1)
+	v2 := js.Global().Call("g")
+	o.Set(js.Global().Call("String", v2).String(), o.Get(js.Global().Call("String", v2).String()).Float()*2)
+	o.Set("n", o.Get("n").Float()+1)
+}`,
+	},
+	{
+		name: "new of JS constructors",
+		js:   `function mk(u) { let d = new Date(); let w = new WebSocket(u, ["v1"]); return new window.URL(u) }`,
+		want: `func mk(u js.Value) js.Value {
+	d := js.Global().Get("Date").New()
+	w := js.Global().Get("WebSocket").New(u, []interface{}{"v1"})
+	return js.Global().Get("URL").New(u)
+}`,
+	},
+	{
+		name: "new of translated class",
+		js: `class Point { constructor(x, y) { this.x = x; this.y = y } }
+function origin() { return new Point(0) }`,
+		want: `type Point struct {
+	x js.Value
+	y js.Value
+}
+
+func NewPoint(x js.Value, y js.Value) *Point {
+	p := &Point{}
+	p.x = x
+	p.y = y
+	return p
+}
+func origin() *Point {
+	return NewPoint(js.ValueOf(0), js.Undefined())
+}`,
+	},
+	{
+		name: "index of slices",
+		js:   `function f(...xs) { let a = []; a[0] = 1; a[xs.length] = g(); return a[5] }`,
+		want: `func f(xs ...interface{}) interface{} {
+	a := []interface{}{}
+	for len(a) <= 0 {
+		a = append(a, js.Undefined())
+	}
+	a[0] = 1
+	i1 := len(xs)
+	v2 := js.Global().Call("g")
+	for len(a) <= i1 {
+		a = append(a, js.Undefined())
+	}
+	a[i1] = v2
+	return func() interface{} {
+		if 5 < len(a) {
+			return a[5]
+		}
+		return js.Undefined()
+	}()
+}`,
+	},
+	{
+		name: "class extending translated class",
+		js: `class A {
+  constructor(x) { this.x = x }
+  get() { return this.x }
+}
+class B extends A {
+  constructor(x, y) { super(x); this.y = y }
+  sum() { return super.get() + this.y }
+}`,
+		want: `type A struct {
+	x js.Value
+}
+
+func NewA(x js.Value) *A {
+	a := &A{}
+	a.x = x
+	return a
+}
+func (a *A) get() js.Value {
+	return a.x
+}
+
+type B struct {
+	*A
+	y js.Value
+}
+
+func NewB(x js.Value, y js.Value) *B {
+	b := &B{}
+	b.A = NewA(x)
+	b.y = y
+	return b
+}
+func (b *B) sum() float64 {
+	return b.A.get().Float() + b.y.Float()
+}`,
+	},
+	{
+		name: "member with float key",
+		js:   `function f(x) { let o = {}; o[x / 2] = 1; return u[x / 2] }`,
+		want: `func f(x js.Value) js.Value {
+	o := map[string]interface{}{}
+	o[js.Global().Call("String", x.Float()/2).String()] = 1
+	return js.Global().Get("u").Get(js.Global().Call("String", x.Float()/2).String())
+}`,
+	},
+	{
+		name: "template with floats",
+		js: `function f(x) {
+  let y = x / 3
+  return ` + "`" + `${y} ${x} ${1.5} ${1e21}` + "`" + ` + y
+}`,
+		want: `func f(x js.Value) string {
+	y := x.Float() / 3
+	return fmt.Sprintf("%s %s %s %s", js.Global().Call("String", y).String(), js.Global().Call("String", x).String(), "1.5", "1e+21") + js.Global().Call("String", y).String()
+}`,
+	},
+	{
+		name: "tagged template",
+		js: `function f(x) {
+  return String.raw` + "`" + `a\n${x}b` + "`" + `
+}`,
+		want: `func f(x js.Value) js.Value {
+	return js.Global().Get("String").Call("raw", js.Global().Get("Object").Call("assign", []interface{}{
+		"a\n",
+		"b",
+	}, map[string]interface{}{"raw": []interface{}{
+		"a\\n",
+		"b",
+	}}), x)
+}`,
+	},
+	{
+		name: "destructuring declarations",
+		js: `function f(o, xs) {
+  const {a, b: c = 1} = o
+  const [x, , y = 2, ...zs] = xs
+  return a + c + x + y + zs.length
+}`,
+		want: `func f(o js.Value, xs js.Value) float64 {
+	a := o.Get("a")
+	c := o.Get("b")
+	if c.IsUndefined() {
+		c = js.ValueOf(1)
+	}
+	x := xs.Index(0)
+	y := xs.Index(2)
+	if y.IsUndefined() {
+		y = js.ValueOf(2)
+	}
+	zs := xs.Call("slice", 3)
+	return (((a.Float() + c.Float()) + x.Float()) + y.Float()) + zs.Get("length").Float()
+}`,
+	},
+	{
+		name: "destructuring parameters",
+		js: `function g({x, y}, [z]) {
+  return x + z
+}`,
+		want: `func g(arg1 js.Value, arg2 js.Value) float64 {
+	x := arg1.Get("x")
+	y := arg1.Get("y")
+	z := arg2.Index(0)
+	return x.Float() + z.Float()
+}`,
+	},
+	{
+		name: "destructuring assignment and loop",
+		js: `function f(xs) {
+  let a, b
+  [a, b] = [b, a]
+  for (const {k, v} of xs) { g(k, v) }
+}`,
+		want: `func f(xs js.Value) {
+	var a js.Value
+	var b js.Value
+	a, b = b, a
+	it1 := xs
+	it1 = js.Global().Get("Reflect").Call("get", it1, js.Global().Get("Symbol").Get("iterator")).Call("call", it1)
+	for step2 := it1.Call("next"); !step2.Get("done").Bool(); step2 = it1.Call("next") {
+		src3 := step2.Get("value")
+		k := src3.Get("k")
+		v := src3.Get("v")
+		js.Global().Call("g", k, v)
+	}
+}`,
+	},
+	{
+		name: "unary operators",
+		js: `function f(x, s) {
+  let n = 1
+  return [+x, -x, -n, typeof x, typeof n, void g(), +s.value]
+}`,
+		want: `func f(x js.Value, s js.Value) []interface{} {
+	n := 1
+	js.Global().Call("g")
+	return []interface{}{
+		js.Global().Call("Number", x).Float(),
+		-js.Global().Call("Number", x).Float(),
+		-n,
+		func() string {
+			if t := x.Type(); t != js.TypeNull {
+				return t.String()
+			}
+			return "object"
+		}(),
+		"number",
+		js.Undefined(),
+		js.Global().Call("Number", s.Get("value")).Float(),
+	}
+}`,
+	},
+	{
+		name: "spread and rest parameters",
+		js: `function f(a, ...xs) {
+  return xs.length
+}
+function g(u) {
+  let ys = [1, 2]
+  h(...ys, u)
+  return f(0, ...ys, 3) + [...ys, ...u].length
+}`,
+		want: `func f(a js.Value, xs ...interface{}) int {
+	return len(xs)
+}
+func g(u js.Value) int {
+	ys := []interface{}{
+		1,
+		2,
+	}
+	js.Global().Call("h", append(append([]interface{}{}, ys...), u)...)
+	return f(js.ValueOf(0), append(append([]interface{}{}, ys...), 3)...) + len(append(append([]interface{}{}, ys...), func() []interface{} {
+		arr1 := js.Global().Get("Array").Call("from", u)
+		res2 := make([]interface{}, arr1.Length())
+		for i := range res2 {
+			res2[i] = arr1.Index(i)
+		}
+		return res2
+	}()...))
+}`,
+	},
+	{
+		name: "translated functions as JS values",
+		js: `function h(e) {
+  console.log(e)
+}
+function main() {
+  const f = (a, b) => a
+  btn.addEventListener("click", h)
+  x.onload = f
+}`,
+		want: `func h(e js.Value) {
+	js.Global().Get("console").Call("log", e)
+}
+func main() {
+	f := func(a js.Value, b js.Value) js.Value {
+		return a
+	}
+	js.Global().Get("btn").Call("addEventListener", "click", js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		args = append(args, make([]js.Value, 1)...)
+		a1 := args[0]
+		h(a1)
+		return nil
+	}))
+	js.Global().Get("x").Set("onload", js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		args = append(args, make([]js.Value, 2)...)
+		a2, a3 := args[0], args[1]
+		return f(a2, a3)
+	}))
+}`,
+	},
+}
+
+func TestParseProgram(t *testing.T) {
+	for _, tt := range parseTests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := translate(tt.js)
+			if err != nil {
+				t.Fatalf("translate(%q): %v\n%s", tt.js, err, got)
+			}
+			if got, want := strings.TrimSpace(got), strings.TrimSpace(tt.want); got != want {
+				t.Errorf("translate(%q):\n%s\nwant:\n%s", tt.js, got, want)
+			}
+		})
+	}
+}
+
+var errorTests = []struct {
+	name string
+	js   string
+	err  string
+}{
+	{
+		name: "this outside of callback",
+		js:   `function f() { return this.x }`,
+		err:  "unsupported this in function not called by JS",
+	},
+	{
+		name: "break out of finally",
+		js:   `function f() { while (a) { try { x() } finally { break } } }`,
+		err:  "unsupported jump out of finally block",
+	},
+	{
+		name: "return out of finally",
+		js:   `function f() { try { x() } finally { return 1 } }`,
+		err:  "unsupported jump out of finally block",
+	},
+	{
+		name: "comparison of slices",
+		js:   `function f(a) { let xs = [], ys = []; return xs === ys }`,
+		err:  "unsupported comparison of Go slice or map",
+	},
+	{
+		name: "class extending JS class",
+		js:   `class C extends HTMLElement { constructor() { super() } }`,
+		err:  "unsupported class C extending HTMLElement, only translated classes can be extended",
+	},
+	{
+		name: "super outside of class",
+		js:   `function f() { return super.x() }`,
+		err:  "unsupported super outside of derived class method",
+	},
+	{
+		name: "spread into fixed parameters",
+		js:   `function f(a, ...xs) {} function g(ys) { f(...ys) }`,
+		err:  "unsupported spread in call of Go function",
+	},
+}
+
+func TestParseProgramError(t *testing.T) {
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := translate(tt.js); err == nil || err.Error() != tt.err {
+				t.Errorf("translate(%q) error = %v, want %q", tt.js, err, tt.err)
+			}
+		})
+	}
+}
+
+// The bundled esprima 4 predates ES2020 and parses neither optional
+// chains nor ??, so their tests return a hand-built ESTree expression
+// from the function f of a parsed program.
+var optionalTests = []struct {
+	name string
+	expr map[string]interface{}
+	want string
+}{
+	{
+		name: "optional member", // a?.b.c
+		expr: chain(member(member(ident("a"), "b", true), "c", false)),
+		want: `func f(a js.Value, b js.Value) js.Value {
+	return func() js.Value {
+		if a.IsUndefined() || a.IsNull() {
+			return js.Undefined()
+		}
+		return a.Get("b").Get("c")
+	}()
+}`,
+	},
+	{
+		name: "optional method call", // a?.b(1)
+		expr: chain(call(member(ident("a"), "b", true), false, literal(1))),
+		want: `func f(a js.Value, b js.Value) js.Value {
+	return func() js.Value {
+		if a.IsUndefined() || a.IsNull() {
+			return js.Undefined()
+		}
+		return a.Call("b", 1)
+	}()
+}`,
+	},
+	{
+		name: "optional call", // a.f?.(b)
+		expr: chain(call(member(ident("a"), "f", false), true, ident("b"))),
+		want: `func f(a js.Value, b js.Value) js.Value {
+	return func() js.Value {
+		v1 := a.Get("f")
+		if v1.IsUndefined() || v1.IsNull() {
+			return js.Undefined()
+		}
+		return v1.Call("call", a, b)
+	}()
+}`,
+	},
+	{
+		name: "nullish coalescing", // a ?? b
+		expr: nullish(ident("a"), ident("b")),
+		want: `func f(a js.Value, b js.Value) js.Value {
+	return func() js.Value {
+		if v := a; !v.IsUndefined() && !v.IsNull() {
+			return v
+		}
+		return b
+	}()
+}`,
+	},
+	{
+		name: "nullish coalescing of optional chain", // a?.b ?? b
+		expr: nullish(chain(member(ident("a"), "b", true)), ident("b")),
+		want: `func f(a js.Value, b js.Value) js.Value {
+	return func() js.Value {
+		if a.IsUndefined() || a.IsNull() {
+			return b
+		}
+		if v := a.Get("b"); !v.IsUndefined() && !v.IsNull() {
+			return v
+		}
+		return b
+	}()
+}`,
+	},
+}
+
+func TestParseOptionalChain(t *testing.T) {
+	for _, tt := range optionalTests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog := esprima.Call("parseScript", `function f(a, b) { return 0 }`)
+			prog.Get("body").Index(0).Get("body").Get("body").Index(0).Set("argument", tt.expr)
+			got, err := translateAST(prog)
+			if err != nil {
+				t.Fatalf("translate: %v\n%s", err, got)
+			}
+			if got, want := strings.TrimSpace(got), strings.TrimSpace(tt.want); got != want {
+				t.Errorf("translate:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func ident(name string) map[string]interface{} {
+	return map[string]interface{}{"type": "Identifier", "name": name}
+}
+
+func literal(v interface{}) map[string]interface{} {
+	return map[string]interface{}{"type": "Literal", "value": v}
+}
+
+func member(object map[string]interface{}, name string, optional bool) map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "MemberExpression",
+		"object":   object,
+		"property": ident(name),
+		"computed": false,
+		"optional": optional,
+	}
+}
+
+func call(callee map[string]interface{}, optional bool, args ...interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"type":      "CallExpression",
+		"callee":    callee,
+		"arguments": args,
+		"optional":  optional,
+	}
+}
+
+func chain(expr map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"type": "ChainExpression", "expression": expr}
+}
+
+func nullish(left, right map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"type": "LogicalExpression", "operator": "??", "left": left, "right": right}
+}